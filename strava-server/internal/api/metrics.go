@@ -0,0 +1,38 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mckusa/strava-server/internal/metrics"
+)
+
+// metricsMiddleware records request count and latency per route. It
+// reads c.Path() (the matched route pattern) rather than the raw URL
+// so requests to /api/stats/1 and /api/stats/2 share one series
+// instead of creating one per userId.
+func metricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			route := c.Path()
+			method := c.Request().Method
+			status := c.Response().Status
+			if err != nil {
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				}
+			}
+
+			metrics.HTTPRequestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
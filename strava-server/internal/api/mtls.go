@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/pkg/config"
+)
+
+// loadTLSConfig builds the tls.Config used to terminate TLS when mTLS
+// is enabled: the server's own certificate, plus a ClientCAs pool that
+// lets Go verify the device certs ESP32s present against it. It applies
+// to the server's single listener, so every route is served under it,
+// not only the ESP32 API group; in "require" mode any client without a
+// cert signed by the CA is refused at the handshake.
+func loadTLSConfig(sec config.SecurityConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(sec.ServerCertFile, sec.ServerKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(sec.ESP32MTLSCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mTLS CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", sec.ESP32MTLSCAFile)
+	}
+
+	clientAuth := tls.VerifyClientCertIfGiven
+	if sec.ESP32MTLSMode == "require" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   clientAuth,
+	}, nil
+}
+
+// esp32AuthMiddleware enforces the ESP32 group's auth: a per-device
+// allow-list lookup when the client presented a cert Go's TLS stack
+// already verified against the CA, falling back to the static API key
+// when mTLS is off (or optional and no cert was presented).
+func esp32AuthMiddleware(sec config.SecurityConfig, queries *database.Queries) echo.MiddlewareFunc {
+	apiKeyCheck := apiKeyMiddleware(sec.ESP32APIKey)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if sec.ESP32MTLSMode == "off" {
+				return apiKeyCheck(next)(c)
+			}
+
+			tlsState := c.Request().TLS
+			if tlsState == nil || len(tlsState.PeerCertificates) == 0 {
+				if sec.ESP32MTLSMode == "require" {
+					return echo.NewHTTPError(http.StatusUnauthorized, "Client certificate required")
+				}
+				return apiKeyCheck(next)(c)
+			}
+
+			peerCert := tlsState.PeerCertificates[0]
+			device, err := queries.GetESP32DeviceByCN(c.Request().Context(), peerCert.Subject.CommonName)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unknown device certificate")
+			}
+			if device.Disabled || device.Serial != peerCert.SerialNumber.String() {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Device certificate not authorized")
+			}
+
+			return next(c)
+		}
+	}
+}
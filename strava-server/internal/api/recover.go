@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/mckusa/strava-server/pkg/logger"
+)
+
+// panicRecoverMiddleware replaces echo's middleware.Recover() so a
+// panic is logged through Zap (with a stack trace) instead of just
+// being swallowed into a generic 500. The buffered sink is flushed
+// before responding, since a panic is exactly the kind of event whose
+// log entry we can't afford to lose if the process is later killed.
+func panicRecoverMiddleware(log *logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			defer func() {
+				if r := recover(); r != nil {
+					req := c.Request()
+
+					// The "level" field is appended after Zap's own
+					// level key in the JSON line; on the sinks it
+					// intentionally overrides it so crash entries are
+					// tagged "panic" rather than "error" and can be
+					// pulled out via the existing GetLogsByLevel route.
+					log.Error("Panic recovered",
+						zap.Any("panic", r),
+						zap.Stack("stack"),
+						zap.String("method", req.Method),
+						zap.String("path", req.URL.Path),
+						zap.String("ip", c.RealIP()),
+						zap.String("level", "panic"),
+					)
+
+					if err := log.Flush(); err != nil {
+						log.Error("Failed to flush logs after panic", zap.Error(err))
+					}
+
+					c.Error(echo.NewHTTPError(http.StatusInternalServerError, "Internal server error"))
+				}
+			}()
+			return next(c)
+		}
+	}
+}
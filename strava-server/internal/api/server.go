@@ -0,0 +1,241 @@
+// Package api wires the HTTP surface of the server: route groups,
+// middleware, and the Echo instance's lifecycle. It depends on
+// app.Dependencies for everything it needs to construct handlers.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+
+	"github.com/mckusa/strava-server/internal/app"
+	"github.com/mckusa/strava-server/internal/cache"
+	"github.com/mckusa/strava-server/internal/handlers"
+	"github.com/mckusa/strava-server/internal/metrics"
+	"github.com/mckusa/strava-server/internal/webhooks"
+	"github.com/mckusa/strava-server/pkg/logger"
+)
+
+// Server owns the Echo instance and the handlers registered on it.
+type Server struct {
+	deps    *app.Dependencies
+	echo    *echo.Echo
+	metrics *http.Server
+
+	oauthHandler *handlers.OAuthHandler
+	apiHandler   *handlers.APIHandler
+	logHandler   *handlers.LogHandler
+}
+
+// New builds a Server and registers its routes, ready to Run.
+func New(deps *app.Dependencies) *Server {
+	s := &Server{
+		deps:         deps,
+		echo:         echo.New(),
+		metrics:      metrics.NewServer(deps.Config.Server.MetricsBindAddr, deps.Config.Server.MetricsPort, deps.Config.Security.ESP32APIKey),
+		oauthHandler: handlers.NewOAuthHandler(deps.Queries, deps.StravaClient, deps.SessionManager),
+		apiHandler:   handlers.NewAPIHandler(deps.Queries, deps.StravaClient, deps.Config.Strava.WebhookVerifyToken, deps.Cache, deps.Logger, deps.SyncScheduler, deps.TokenRefresher),
+		logHandler:   handlers.NewLogHandler(deps.Queries, deps.Logger),
+	}
+
+	s.echo.HidePort = true
+	s.echo.HideBanner = true
+	s.echo.Use(zapRequestLogger(deps.Logger))
+	s.echo.Use(panicRecoverMiddleware(deps.Logger))
+	s.echo.Use(middleware.CORS())
+	s.echo.Use(metricsMiddleware())
+
+	s.SetupRoutes(s.echo)
+
+	return s
+}
+
+// SetupRoutes registers every route group on e.
+func (s *Server) SetupRoutes(e *echo.Echo) {
+	e.GET("/health", func(c echo.Context) error {
+		return c.JSON(200, map[string]string{
+			"status": "ok",
+			"time":   time.Now().Format(time.RFC3339),
+		})
+	})
+
+	s.registerOAuthRoutes(e)
+	s.registerWebhookRoutes(e)
+	s.registerESP32Routes(e)
+	s.registerSessionRoutes(e)
+	s.registerAdminRoutes(e)
+}
+
+func (s *Server) registerOAuthRoutes(e *echo.Echo) {
+	e.GET("/auth/login", s.oauthHandler.HandleLogin)
+	e.GET("/auth/callback", s.oauthHandler.HandleCallback)
+	e.POST("/auth/logout", s.oauthHandler.HandleLogout)
+}
+
+// registerSessionRoutes registers endpoints for browsers that hold a
+// session cookie from the OAuth flow, as opposed to the ESP32 group's
+// device-credential auth.
+func (s *Server) registerSessionRoutes(e *echo.Echo) {
+	meGroup := e.Group("/me")
+	meGroup.Use(RequireSession(s.deps.SessionManager))
+
+	meGroup.GET("/activities/recent", s.apiHandler.GetMyRecentActivities)
+}
+
+func (s *Server) registerWebhookRoutes(e *echo.Echo) {
+	e.GET(webhooks.CallbackPath, s.apiHandler.HandleWebhookVerification)
+	e.POST(webhooks.CallbackPath, s.apiHandler.HandleStravaWebhook)
+}
+
+// registerESP32Routes registers the device-credential-authenticated
+// :userId routes. EnforceSessionOwnership additionally stops a browser
+// that's logged in as one user from reaching another user's data
+// through these routes by editing the path; it's a no-op for ESP32
+// devices, which never present a session cookie.
+func (s *Server) registerESP32Routes(e *echo.Echo) {
+	cacheCfg := s.deps.Config.Cache
+
+	apiGroup := e.Group("/api")
+	apiGroup.Use(esp32AuthMiddleware(s.deps.Config.Security, s.deps.Queries))
+	apiGroup.Use(EnforceSessionOwnership(s.deps.SessionManager))
+
+	apiGroup.GET("/activities/recent/:userId", s.apiHandler.GetRecentActivities,
+		cache.Middleware(s.deps.Cache, time.Duration(cacheCfg.RecentTTLSeconds)*time.Second))
+	apiGroup.GET("/activities/calendar/:userId/:year/:month", s.apiHandler.GetCalendarData,
+		cache.Middleware(s.deps.Cache, time.Duration(cacheCfg.CalendarTTLSeconds)*time.Second))
+	apiGroup.GET("/stats/:userId", s.apiHandler.GetUserStats,
+		cache.Middleware(s.deps.Cache, time.Duration(cacheCfg.StatsTTLSeconds)*time.Second))
+
+	apiGroup.POST("/users/:userId/sync/enable", s.apiHandler.EnableSync)
+	apiGroup.POST("/users/:userId/sync/disable", s.apiHandler.DisableSync)
+	apiGroup.GET("/users/:userId/sync/status", s.apiHandler.SyncStatus)
+	apiGroup.GET("/users/:userId/freshness", s.apiHandler.GetFreshness)
+}
+
+func (s *Server) registerAdminRoutes(e *echo.Echo) {
+	admin := e.Group("/admin")
+	admin.Use(middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
+		return username == s.deps.Config.Security.AdminUsername && password == s.deps.Config.Security.AdminPassword, nil
+	}))
+
+	admin.POST("/sync/:userId", s.apiHandler.SyncActivities)
+	admin.GET("/strava/ratelimit", s.apiHandler.GetRateLimitStatus)
+	admin.GET("/cache/stats", func(c echo.Context) error {
+		return c.JSON(200, s.deps.Cache.Stats())
+	})
+
+	admin.GET("/logs", s.logHandler.GetRecentLogs)
+	admin.GET("/logs/level/:level", s.logHandler.GetLogsByLevel)
+	admin.GET("/logs/user/:userId", s.logHandler.GetLogsByUserID)
+	admin.GET("/logs/level", s.logHandler.GetLogLevel)
+	admin.PUT("/logs/level", s.logHandler.SetLogLevel)
+}
+
+// Run starts the Echo server in the background and bootstraps the
+// Strava webhook subscription. It does not block; call Shutdown to
+// stop the server.
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.deps.Config
+
+	if cfg.Strava.WebhookVerifyToken != "" {
+		callbackURL := cfg.Server.Domain + webhooks.CallbackPath
+		if _, err := webhooks.Bootstrap(s.deps.StravaClient, callbackURL, cfg.Strava.WebhookVerifyToken); err != nil {
+			s.deps.Logger.Error("Failed to reconcile Strava webhook subscription", zap.Error(err))
+		}
+	}
+
+	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+	s.deps.Logger.Info("Server starting",
+		zap.String("address", addr),
+		zap.String("oauth_url", cfg.Server.Domain+"/auth/login"),
+		zap.String("esp32_mtls_mode", cfg.Security.ESP32MTLSMode),
+	)
+
+	if cfg.Security.ESP32MTLSMode == "off" {
+		go func() {
+			if err := s.echo.Start(addr); err != nil {
+				s.deps.Logger.Error("Server error", zap.Error(err))
+			}
+		}()
+	} else {
+		tlsConfig, err := loadTLSConfig(cfg.Security)
+		if err != nil {
+			return fmt.Errorf("failed to configure mTLS: %w", err)
+		}
+
+		s.echo.TLSServer.Addr = addr
+		s.echo.TLSServer.TLSConfig = tlsConfig
+		go func() {
+			if err := s.echo.StartServer(s.echo.TLSServer); err != nil {
+				s.deps.Logger.Error("Server error", zap.Error(err))
+			}
+		}()
+	}
+
+	go func() {
+		s.deps.Logger.Info("Metrics server starting", zap.String("address", s.metrics.Addr))
+		if err := s.metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.deps.Logger.Error("Metrics server error", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown gracefully stops the Echo server and the metrics server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.deps.Logger.Info("Shutting down server...")
+	if err := s.metrics.Shutdown(ctx); err != nil {
+		s.deps.Logger.Error("Metrics server shutdown error", zap.Error(err))
+	}
+	return s.echo.Shutdown(ctx)
+}
+
+// apiKeyMiddleware validates the API key for ESP32 requests.
+func apiKeyMiddleware(expectedKey string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := c.Request().Header.Get("X-API-Key")
+			if apiKey == "" || apiKey != expectedKey {
+				return echo.NewHTTPError(401, "Invalid or missing API key")
+			}
+			return next(c)
+		}
+	}
+}
+
+// zapRequestLogger is a middleware that logs HTTP requests using Zap.
+func zapRequestLogger(log *logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			req := c.Request()
+			res := c.Response()
+
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("path", req.URL.Path),
+				zap.Int("status", res.Status),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("ip", c.RealIP()),
+			}
+
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+				log.Error("Request failed", fields...)
+			} else {
+				log.Info("Request completed", fields...)
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mckusa/strava-server/internal/session"
+)
+
+// RequireSession populates "userID" on the echo context from the
+// caller's signed session cookie, or rejects the request with 401 if
+// it's missing, unsigned, or expired. Routes behind it can trust
+// c.Get("userID") instead of a path parameter the caller controls.
+func RequireSession(sessionMgr *session.Manager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := sessionMgr.UserID(c)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing or expired session")
+			}
+
+			c.Set("userID", userID)
+			return next(c)
+		}
+	}
+}
+
+// EnforceSessionOwnership rejects a request to a :userId route if the
+// caller is presenting a session cookie for a *different* user. ESP32
+// devices authenticate with the shared API key and never carry a
+// session cookie, so they pass through untouched; this only stops a
+// logged-in browser from using its valid credentials to reach another
+// user's data by editing the path.
+func EnforceSessionOwnership(sessionMgr *session.Manager) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sessionUserID, ok := sessionMgr.UserID(c)
+			if !ok {
+				return next(c)
+			}
+
+			pathUserID, err := strconv.ParseInt(c.Param("userId"), 10, 32)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid userId")
+			}
+
+			if int32(pathUserID) != sessionUserID {
+				return echo.NewHTTPError(http.StatusForbidden, "Session does not own this user")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package app assembles the server's dependencies: config, database
+// pool, sqlc queries, Strava client, logger, and log flusher. It is
+// the single place that knows how to construct and tear down the
+// long-lived resources the rest of the server depends on.
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/mckusa/strava-server/internal/cache"
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/services"
+	"github.com/mckusa/strava-server/internal/session"
+	"github.com/mckusa/strava-server/internal/strava"
+	"github.com/mckusa/strava-server/internal/sync"
+	"github.com/mckusa/strava-server/pkg/config"
+	"github.com/mckusa/strava-server/pkg/logger"
+)
+
+// syncPollInterval is how often the sync scheduler checks sync_tasks
+// for due work.
+const syncPollInterval = 30 * time.Second
+
+// Dependencies holds every long-lived resource the API server needs.
+type Dependencies struct {
+	Config         *config.Config
+	DB             *pgxpool.Pool
+	Queries        *database.Queries
+	StravaClient   *strava.Client
+	Logger         *logger.Logger
+	LogFlusher     *services.LogFlusher
+	Cache          cache.Cache
+	SyncScheduler  *sync.Scheduler
+	SessionManager *session.Manager
+	TokenRefresher *services.TokenRefresher
+}
+
+// New loads configuration and connects to the database, returning a
+// fully wired Dependencies. Callers must defer Close().
+func New(ctx context.Context) (*Dependencies, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	dbpool, err := pgxpool.New(ctx, cfg.Database.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := dbpool.Ping(ctx); err != nil {
+		dbpool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	queries := database.New(dbpool)
+
+	log, err := logger.NewLogger(queries, cfg.Logging, true) // true = development mode
+	if err != nil {
+		dbpool.Close()
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	log.Info("Database connected successfully")
+
+	logFlusher := services.NewLogFlusher(log)
+	if err := logFlusher.Start(flushSchedule(cfg.Logging)); err != nil {
+		dbpool.Close()
+		return nil, fmt.Errorf("failed to start log flusher: %w", err)
+	}
+
+	stravaClient := strava.NewClient(
+		cfg.Strava.ClientID,
+		cfg.Strava.ClientSecret,
+		cfg.Strava.RedirectURI,
+	)
+
+	respCache, err := cache.New(cfg.Cache.Backend, cfg.Cache.RedisAddr)
+	if err != nil {
+		dbpool.Close()
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
+	tokenRefresher := services.NewTokenRefresher(queries, stravaClient)
+
+	syncScheduler := sync.NewScheduler(queries, stravaClient, respCache, log, cfg.Server.SyncWorkers, tokenRefresher)
+	syncScheduler.Start(syncPollInterval)
+
+	sessionManager := session.NewManager(
+		[]byte(cfg.Security.SessionSecret),
+		time.Duration(cfg.Security.SessionTTLSeconds)*time.Second,
+		strings.HasPrefix(cfg.Server.Domain, "https://"),
+	)
+
+	return &Dependencies{
+		Config:         cfg,
+		DB:             dbpool,
+		Queries:        queries,
+		StravaClient:   stravaClient,
+		Logger:         log,
+		LogFlusher:     logFlusher,
+		Cache:          respCache,
+		SyncScheduler:  syncScheduler,
+		SessionManager: sessionManager,
+		TokenRefresher: tokenRefresher,
+	}, nil
+}
+
+// flushSchedule picks the log flusher's cron schedule. The S3 sink has
+// no ticker of its own, so when it's enabled the flusher runs on its
+// configured interval instead of the default 5 minutes.
+func flushSchedule(logging config.LoggingConfig) string {
+	for _, name := range logging.Sinks {
+		if name == "s3" {
+			return fmt.Sprintf("@every %ds", logging.S3FlushInterval)
+		}
+	}
+	return "*/5 * * * *"
+}
+
+// Close releases every resource New acquired, in reverse order.
+func (d *Dependencies) Close() {
+	d.SyncScheduler.Stop()
+	d.LogFlusher.Stop()
+	d.Logger.Sync()
+	d.Logger.Close()
+	d.DB.Close()
+}
@@ -0,0 +1,25 @@
+// Package cache provides a small response cache abstraction backed
+// either by an in-process TTL cache or Redis, selected via
+// CACHE_BACKEND. It sits in front of the read-heavy ESP32 endpoints so
+// a fixed-interval poller doesn't re-query Postgres (and potentially
+// trigger a fresh Strava fetch) on every request.
+package cache
+
+import "time"
+
+// Cache is the interface both backends implement.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	// Invalidate removes every key with the given prefix, e.g.
+	// "user:42:" to drop all cached responses for user 42.
+	Invalidate(prefix string)
+	// Stats reports cumulative hit/miss counters for GET /admin/cache/stats.
+	Stats() Stats
+}
+
+// Stats are cumulative counters since the cache was created.
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
@@ -0,0 +1,18 @@
+package cache
+
+import "fmt"
+
+// New builds the configured backend: "memory" (default) or "redis".
+func New(backend, redisAddr string) (Cache, error) {
+	switch backend {
+	case "", "memory":
+		return NewMemoryCache(defaultShardCount, 10_000), nil
+	case "redis":
+		if redisAddr == "" {
+			return nil, fmt.Errorf("CACHE_REDIS_ADDR is required when CACHE_BACKEND=redis")
+		}
+		return NewRedisCache(redisAddr), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", backend)
+	}
+}
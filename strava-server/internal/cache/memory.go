@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultShardCount = 16
+
+// entry is one cached value plus its expiry and last-access time, the
+// latter used to pick an eviction victim once a shard is full.
+type entry struct {
+	value      []byte
+	expiresAt  time.Time
+	lastAccess time.Time
+}
+
+type shard struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// MemoryCache is a sharded, TTL-aware, capacity-bounded cache. Sharding
+// spreads lock contention across goroutines hitting different keys;
+// each shard independently evicts its least-recently-used entry once
+// it exceeds maxEntriesPerShard.
+type MemoryCache struct {
+	shards             []*shard
+	maxEntriesPerShard int
+
+	hits   int64
+	misses int64
+}
+
+// NewMemoryCache builds a cache capped at roughly maxEntries total,
+// split evenly across shardCount shards.
+func NewMemoryCache(shardCount, maxEntries int) *MemoryCache {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	if maxEntries <= 0 {
+		maxEntries = 10_000
+	}
+
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{entries: make(map[string]*entry)}
+	}
+
+	return &MemoryCache{
+		shards:             shards,
+		maxEntriesPerShard: maxEntries / shardCount,
+	}
+}
+
+func (m *MemoryCache) shardFor(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	s := m.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		delete(s.entries, key)
+		atomic.AddInt64(&m.misses, 1)
+		return nil, false
+	}
+
+	e.lastAccess = time.Now()
+	atomic.AddInt64(&m.hits, 1)
+	return e.value, true
+}
+
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	s := m.shardFor(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.maxEntriesPerShard > 0 && len(s.entries) >= m.maxEntriesPerShard {
+		if _, exists := s.entries[key]; !exists {
+			evictLRULocked(s)
+		}
+	}
+
+	now := time.Now()
+	s.entries[key] = &entry{
+		value:      value,
+		expiresAt:  now.Add(ttl),
+		lastAccess: now,
+	}
+}
+
+// evictLRULocked drops the least-recently-accessed entry in s. Callers
+// must already hold s.mu.
+func evictLRULocked(s *shard) {
+	var oldestKey string
+	var oldestAccess time.Time
+	first := true
+
+	for key, e := range s.entries {
+		if first || e.lastAccess.Before(oldestAccess) {
+			oldestKey = key
+			oldestAccess = e.lastAccess
+			first = false
+		}
+	}
+
+	if !first {
+		delete(s.entries, oldestKey)
+	}
+}
+
+func (m *MemoryCache) Invalidate(prefix string) {
+	for _, s := range m.shards {
+		s.mu.Lock()
+		for key := range s.entries {
+			if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (m *MemoryCache) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadInt64(&m.hits),
+		Misses: atomic.LoadInt64(&m.misses),
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware caches successful (2xx) JSON GET responses for ttl, keyed
+// per userId so invalidation can drop everything for one user without
+// touching another's cached data. A request sending
+// "Cache-Control: no-cache" always bypasses the cache.
+func Middleware(c Cache, ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if ctx.Request().Header.Get("Cache-Control") == "no-cache" {
+				return next(ctx)
+			}
+
+			key := Key(ctx.Param("userId"), ctx.Request().URL.Path)
+
+			if cached, ok := c.Get(key); ok {
+				return ctx.JSONBlob(http.StatusOK, cached)
+			}
+
+			recorder := newResponseRecorder(ctx.Response().Writer)
+			ctx.Response().Writer = recorder
+
+			if err := next(ctx); err != nil {
+				return err
+			}
+
+			if recorder.status >= 200 && recorder.status < 300 {
+				c.Set(key, recorder.body.Bytes(), ttl)
+			}
+
+			return nil
+		}
+	}
+}
+
+// Key builds the cache key for a userId-scoped response. Invalidate
+// with the prefix returned by UserPrefix to drop every cached
+// response for that user.
+func Key(userID, path string) string {
+	return fmt.Sprintf("%s%s", UserPrefix(userID), path)
+}
+
+// UserPrefix is the invalidation prefix for everything cached under userID.
+func UserPrefix(userID string) string {
+	return fmt.Sprintf("user:%s:", userID)
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// responseRecorder wraps an http.ResponseWriter to capture the status
+// and body written by the handler while still passing them through to
+// the real client, so Middleware can cache what was just served.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCache is the Cache backend for CACHE_BACKEND=redis, used when
+// the server runs as more than one instance and needs a shared cache.
+type RedisCache struct {
+	client *redis.Client
+
+	hits   int64
+	misses int64
+}
+
+// NewRedisCache connects to addr (host:port).
+func NewRedisCache(addr string) *RedisCache {
+	return &RedisCache{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+	}
+}
+
+func (r *RedisCache) Get(key string) ([]byte, bool) {
+	value, err := r.client.Get(context.Background(), key).Bytes()
+	if err != nil {
+		atomic.AddInt64(&r.misses, 1)
+		return nil, false
+	}
+
+	atomic.AddInt64(&r.hits, 1)
+	return value, true
+}
+
+func (r *RedisCache) Set(key string, value []byte, ttl time.Duration) {
+	r.client.Set(context.Background(), key, value, ttl)
+}
+
+// Invalidate scans for prefix* and deletes matches. Redis has no
+// native prefix-delete, so this is a SCAN + DEL; fine at the volume
+// this cache sees (a handful of keys per user).
+func (r *RedisCache) Invalidate(prefix string) {
+	ctx := context.Background()
+	iter := r.client.Scan(ctx, 0, prefix+"*", 0).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	if len(keys) > 0 {
+		r.client.Del(ctx, keys...)
+	}
+}
+
+func (r *RedisCache) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadInt64(&r.hits),
+		Misses: atomic.LoadInt64(&r.misses),
+	}
+}
@@ -3,25 +3,54 @@ package handlers
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
+	"github.com/mckusa/strava-server/internal/cache"
 	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/services"
 	"github.com/mckusa/strava-server/internal/strava"
+	"github.com/mckusa/strava-server/internal/sync"
+	"github.com/mckusa/strava-server/internal/webhooks"
+	"github.com/mckusa/strava-server/pkg/logger"
+	"go.uber.org/zap"
 )
 
+// isDuplicateKeyError reports whether err is a Postgres unique
+// violation, which recordWebhookEvent relies on to treat a retried
+// webhook delivery as already handled.
+func isDuplicateKeyError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 type APIHandler struct {
-	queries      *database.Queries
-	stravaClient *strava.Client
+	queries            *database.Queries
+	stravaClient       *strava.Client
+	webhookVerifyToken string
+	cache              cache.Cache
+	logger             *logger.Logger
+	syncScheduler      *sync.Scheduler
+	tokenRefresher     *services.TokenRefresher
 }
 
-func NewAPIHandler(queries *database.Queries, stravaClient *strava.Client) *APIHandler {
+func NewAPIHandler(queries *database.Queries, stravaClient *strava.Client, webhookVerifyToken string, respCache cache.Cache, log *logger.Logger, syncScheduler *sync.Scheduler, tokenRefresher *services.TokenRefresher) *APIHandler {
 	return &APIHandler{
-		queries:      queries,
-		stravaClient: stravaClient,
+		queries:            queries,
+		stravaClient:       stravaClient,
+		webhookVerifyToken: webhookVerifyToken,
+		cache:              respCache,
+		logger:             log,
+		syncScheduler:      syncScheduler,
+		tokenRefresher:     tokenRefresher,
 	}
 }
 
@@ -45,6 +74,27 @@ func (h *APIHandler) GetRecentActivities(c echo.Context) error {
 	return c.JSON(http.StatusOK, activities)
 }
 
+// GetMyRecentActivities returns recent activities for the caller's own
+// session, trusting the authenticated session rather than a path
+// parameter the caller could tamper with to read another user's data.
+func (h *APIHandler) GetMyRecentActivities(c echo.Context) error {
+	userID, ok := c.Get("userID").(int32)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Missing session")
+	}
+
+	ctx := c.Request().Context()
+	activities, err := h.queries.GetRecentActivities(ctx, database.GetRecentActivitiesParams{
+		UserID: userID,
+		Limit:  10,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, activities)
+}
+
 // GetCalendarData returns calendar data for a specific month
 func (h *APIHandler) GetCalendarData(c echo.Context) error {
 	userID, err := strconv.Atoi(c.Param("userId"))
@@ -97,87 +147,235 @@ func (h *APIHandler) GetUserStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, stats)
 }
 
-// SyncActivities fetches and stores activities from Strava
-func (h *APIHandler) SyncActivities(c echo.Context) error {
+// Default freshness thresholds and weekly goal, used when a user has
+// no user_preferences row yet.
+const (
+	defaultFreshThresholdHours   = 48
+	defaultWarningThresholdHours = 120
+	defaultWeeklyGoalM           = 20000
+)
+
+// FreshnessResponse is the JSON shape of GET /api/users/:userId/freshness.
+type FreshnessResponse struct {
+	LastActivity    *FreshnessActivity `json:"last_activity,omitempty"`
+	HoursSince      float64            `json:"hours_since"`
+	Staleness       string             `json:"staleness"`
+	StreakDays      int32              `json:"streak_days"`
+	WeeklyDistanceM float64            `json:"weekly_distance_m"`
+	WeeklyGoalPct   float64            `json:"weekly_goal_pct"`
+}
+
+// FreshnessActivity is the most recent activity summarized in a
+// FreshnessResponse.
+type FreshnessActivity struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Distance  float64   `json:"distance"`
+	StartDate time.Time `json:"start_date"`
+}
+
+// GetFreshness reports how recently userId logged an activity, plus a
+// coarse staleness bucket and weekly-goal progress, designed to be
+// polled cheaply by ESP32/CLI/status-bar clients: it honors
+// If-Modified-Since/ETag so an unchanged poll gets a 304, and
+// negotiates a minimal tab-separated text/plain variant for clients
+// that would rather not parse JSON.
+func (h *APIHandler) GetFreshness(c echo.Context) error {
 	userID, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
 	}
-	queryMonth := c.QueryParam("month")
-	queryYear := c.QueryParam("year")
 
-	ctx := context.Background()
+	ctx := c.Request().Context()
 
-	// Get user from database
-	user, err := h.queries.GetUserByID(ctx, int32(userID))
+	row, err := h.queries.GetFreshnessData(ctx, int32(userID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+			return echo.NewHTTPError(http.StatusNotFound, "No activities for this user")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	// Check if token needs refresh
-	accessToken := user.AccessToken
-	if user.TokenExpiresAt.Valid && time.Now().After(user.TokenExpiresAt.Time) {
-		// Refresh token
-		tokenResp, err := h.stravaClient.RefreshToken(user.RefreshToken)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refresh token: "+err.Error())
-		}
+	freshThreshold := time.Duration(defaultFreshThresholdHours) * time.Hour
+	warningThreshold := time.Duration(defaultWarningThresholdHours) * time.Hour
+	weeklyGoalM := float64(defaultWeeklyGoalM)
+
+	prefs, err := h.queries.GetUserPreferences(ctx, int32(userID))
+	switch {
+	case err == nil:
+		freshThreshold = time.Duration(prefs.FreshThresholdHours) * time.Hour
+		warningThreshold = time.Duration(prefs.WarningThresholdHours) * time.Hour
+		weeklyGoalM = prefs.WeeklyGoalM
+	case !errors.Is(err, pgx.ErrNoRows):
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
 
-		// Update tokens in database
-		expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
-		_, err = h.queries.UpdateUserTokens(ctx, database.UpdateUserTokensParams{
-			ID:             user.ID,
-			AccessToken:    tokenResp.AccessToken,
-			RefreshToken:   tokenResp.RefreshToken,
-			TokenExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
-		})
+	lastActivityAt := row.LastActivityStartDate.Time
+	since := time.Since(lastActivityAt)
 
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update tokens: "+err.Error())
-		}
+	staleness := "stale"
+	switch {
+	case since < freshThreshold:
+		staleness = "fresh"
+	case since < warningThreshold:
+		staleness = "warning"
+	}
 
-		accessToken = tokenResp.AccessToken
+	weeklyGoalPct := 0.0
+	if weeklyGoalM > 0 {
+		weeklyGoalPct = (row.WeeklyDistanceM / weeklyGoalM) * 100
 	}
 
-	var activities []strava.Activity
+	etag := fmt.Sprintf(`"%d-%d"`, userID, lastActivityAt.Unix())
+	c.Response().Header().Set("ETag", etag)
+	c.Response().Header().Set("Last-Modified", lastActivityAt.UTC().Format(http.TimeFormat))
 
-	if queryMonth != "" && queryYear != "" {
-		// Fetch activities for specific month
-		month, err := strconv.Atoi(queryMonth)
-		if err != nil || month < 1 || month > 12 {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid month")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if raw := c.Request().Header.Get("If-Modified-Since"); raw != "" {
+		if t, err := http.ParseTime(raw); err == nil && !lastActivityAt.After(t) {
+			return c.NoContent(http.StatusNotModified)
 		}
+	}
 
-		year, err := strconv.Atoi(queryYear)
-		if err != nil || year < 2000 || year > time.Now().Year() {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid year")
-		}
+	hoursSince := since.Hours()
+
+	if acceptsPlainText(c.Request().Header.Get("Accept")) {
+		return c.String(http.StatusOK, fmt.Sprintf("%s\t%.0f\t%d\n", staleness, hoursSince, row.StreakDays))
+	}
 
-		// Calculate date range
-		firstDay := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-		lastDay := firstDay.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	return c.JSON(http.StatusOK, FreshnessResponse{
+		LastActivity: &FreshnessActivity{
+			Name:      row.LastActivityName.String,
+			Type:      row.LastActivityType.String,
+			Distance:  row.LastActivityDistance.Float64,
+			StartDate: lastActivityAt,
+		},
+		HoursSince:      hoursSince,
+		Staleness:       staleness,
+		StreakDays:      row.StreakDays,
+		WeeklyDistanceM: row.WeeklyDistanceM,
+		WeeklyGoalPct:   weeklyGoalPct,
+	})
+}
 
-		activities, err = h.stravaClient.GetActivitiesInRange(accessToken, firstDay.Unix(), lastDay.Unix())
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch activities: "+err.Error())
+// acceptsPlainText reports whether the client's Accept header prefers
+// the minimal text/plain variant over JSON.
+func acceptsPlainText(accept string) bool {
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json")
+}
+
+// SyncActivities enqueues an immediate background sync for the user
+// rather than fetching inline, since a full activity history pull can
+// run long enough to make the HTTP client time out. It returns 202
+// with a job ID; progress can be polled via SyncStatus.
+func (h *APIHandler) SyncActivities(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	ctx := c.Request().Context()
+	if _, err := h.queries.GetUserByID(ctx, int32(userID)); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
 		}
-	} else {
-		// Fetch activities from Strava (last 30 days)
-		thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Unix()
-		activities, err = h.stravaClient.GetActivities(accessToken, thirtyDaysAgo, 100)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch activities: "+err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	jobID, err := h.syncScheduler.EnqueueImmediate(ctx, int32(userID))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to enqueue sync: "+err.Error())
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Sync enqueued",
+		"job_id":  jobID,
+	})
+}
+
+// EnableSync turns on recurring background syncing for userId, at an
+// optional interval_seconds (defaults to sync.DefaultInterval), and
+// schedules an immediate first run.
+func (h *APIHandler) EnableSync(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var req struct {
+		IntervalSeconds int32 `json:"interval_seconds"`
+	}
+	_ = c.Bind(&req)
+
+	interval := sync.DefaultInterval
+	if req.IntervalSeconds > 0 {
+		interval = time.Duration(req.IntervalSeconds) * time.Second
+	}
+
+	task, err := h.syncScheduler.Enable(c.Request().Context(), int32(userID), interval)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, task)
+}
+
+// DisableSync turns off recurring background syncing for userId.
+func (h *APIHandler) DisableSync(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.syncScheduler.Disable(c.Request().Context(), int32(userID)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SyncStatus reports the background sync task state for :userId.
+func (h *APIHandler) SyncStatus(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	task, err := h.syncScheduler.Status(c.Request().Context(), int32(userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "No sync task for this user")
 		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	// Save activities to database
+	return c.JSON(http.StatusOK, task)
+}
+
+// invalidateUserCache drops every cached ESP32 response for userID
+// after new activity data lands, so the next poll sees it immediately
+// instead of waiting out the TTL.
+func (h *APIHandler) invalidateUserCache(userID int32) {
+	h.cache.Invalidate(cache.UserPrefix(strconv.Itoa(int(userID))))
+}
+
+// GetRateLimitStatus reports the Strava API quota observed from the
+// most recent request's rate-limit headers.
+func (h *APIHandler) GetRateLimitStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.stravaClient.RateLimitStatus())
+}
+
+// saveActivities upserts a batch of Strava activities for userID and
+// returns how many succeeded. Used by the webhook handler; the
+// background sync (sync.Scheduler, which SyncActivities enqueues into)
+// upserts through its own loop and does not call this.
+func (h *APIHandler) saveActivities(ctx context.Context, userID int32, activities []strava.Activity) int {
 	saved := 0
 	for _, activity := range activities {
 		_, err := h.queries.UpsertActivity(ctx, database.UpsertActivityParams{
-			UserID:           user.ID,
+			UserID:           userID,
 			StravaActivityID: activity.ID,
 			Name:             pgtype.Text{String: activity.Name, Valid: true},
 			Type:             pgtype.Text{String: activity.Type, Valid: true},
@@ -192,10 +390,129 @@ func (h *APIHandler) SyncActivities(c echo.Context) error {
 			saved++
 		}
 	}
+	return saved
+}
+
+// HandleWebhookVerification answers Strava's GET handshake when a
+// subscription is created, echoing back hub.challenge once the
+// verify token matches our configured secret.
+func (h *APIHandler) HandleWebhookVerification(c echo.Context) error {
+	challenge, ok := webhooks.VerifyChallenge(
+		c.QueryParam("hub.mode"),
+		c.QueryParam("hub.verify_token"),
+		h.webhookVerifyToken,
+		c.QueryParam("hub.challenge"),
+	)
+	if !ok {
+		return echo.NewHTTPError(http.StatusForbidden, "Invalid verify token")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"hub.challenge": challenge})
+}
+
+// HandleStravaWebhook receives push events for activity and athlete
+// updates. Strava retries aggressively on anything but a fast 2xx, so
+// this only validates and deduplicates the event inline, then hands
+// the actual fetch-and-upsert off to a goroutine and returns 200
+// immediately.
+func (h *APIHandler) HandleStravaWebhook(c echo.Context) error {
+	var event webhooks.Event
+	if err := c.Bind(&event); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid webhook payload")
+	}
+
+	ctx := c.Request().Context()
+	isNew, err := h.recordWebhookEvent(ctx, event)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if isNew && event.ObjectType == webhooks.ObjectTypeActivity {
+		if event.AspectType == webhooks.AspectDelete {
+			go h.processActivityDeleteEvent(event)
+		} else {
+			go h.processActivityEvent(event)
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
 
-	return c.JSON(http.StatusOK, map[string]any{
-		"message": "Sync completed",
-		"fetched": len(activities),
-		"saved":   saved,
+// recordWebhookEvent inserts event into webhook_events, relying on its
+// (object_id, event_time, aspect_type) uniqueness constraint to make
+// retried deliveries a no-op. It reports whether this delivery is the
+// first one seen.
+func (h *APIHandler) recordWebhookEvent(ctx context.Context, event webhooks.Event) (bool, error) {
+	_, err := h.queries.InsertWebhookEvent(ctx, database.InsertWebhookEventParams{
+		ObjectID:   event.ObjectID,
+		EventTime:  event.EventTime,
+		AspectType: string(event.AspectType),
+		ObjectType: string(event.ObjectType),
+		OwnerID:    event.OwnerID,
 	})
+	if err == nil {
+		return true, nil
+	}
+	if isDuplicateKeyError(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// processActivityEvent runs in the background after HandleStravaWebhook
+// has already responded 200, fetching and upserting the single
+// activity the event refers to. It uses its own context since the
+// request's is canceled once the handler returns.
+func (h *APIHandler) processActivityEvent(event webhooks.Event) {
+	ctx := context.Background()
+
+	user, err := h.queries.GetUserByStravaID(ctx, event.OwnerID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			h.logger.Error("Webhook: failed to look up user", zap.Int64("owner_id", event.OwnerID), zap.Error(err))
+		}
+		return
+	}
+
+	accessToken, err := h.tokenRefresher.EnsureValidToken(ctx, user)
+	if err != nil {
+		h.logger.Error("Webhook: failed to refresh token", zap.Int32("user_id", user.ID), zap.Error(err))
+		return
+	}
+
+	activity, err := h.stravaClient.GetActivity(accessToken, event.ObjectID)
+	if err != nil {
+		h.logger.Error("Webhook: failed to fetch activity", zap.Int64("activity_id", event.ObjectID), zap.Error(err))
+		return
+	}
+
+	h.saveActivities(ctx, user.ID, []strava.Activity{*activity})
+	h.invalidateUserCache(user.ID)
+}
+
+// processActivityDeleteEvent runs in the background after
+// HandleStravaWebhook has already responded 200, removing the single
+// activity the event refers to from the local DB. Strava's delete
+// events carry owner_id but not a user ID we can trust, so this looks
+// the user up the same way processActivityEvent does.
+func (h *APIHandler) processActivityDeleteEvent(event webhooks.Event) {
+	ctx := context.Background()
+
+	user, err := h.queries.GetUserByStravaID(ctx, event.OwnerID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			h.logger.Error("Webhook: failed to look up user for delete", zap.Int64("owner_id", event.OwnerID), zap.Error(err))
+		}
+		return
+	}
+
+	if err := h.queries.DeleteActivity(ctx, database.DeleteActivityParams{
+		UserID:           user.ID,
+		StravaActivityID: event.ObjectID,
+	}); err != nil {
+		h.logger.Error("Webhook: failed to delete activity", zap.Int64("activity_id", event.ObjectID), zap.Error(err))
+		return
+	}
+
+	h.invalidateUserCache(user.ID)
 }
@@ -9,18 +9,46 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/pkg/logger"
 )
 
 type LogHandler struct {
 	queries *database.Queries
+	logger  *logger.Logger
 }
 
-func NewLogHandler(queries *database.Queries) *LogHandler {
+func NewLogHandler(queries *database.Queries, log *logger.Logger) *LogHandler {
 	return &LogHandler{
 		queries: queries,
+		logger:  log,
 	}
 }
 
+// levelRequest is the body PUT /admin/logs/level expects.
+type levelRequest struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel returns the logger's current verbosity.
+func (h *LogHandler) GetLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, levelRequest{Level: h.logger.LevelName()})
+}
+
+// SetLogLevel updates the logger's verbosity at runtime, e.g. to flip
+// to debug while chasing a live issue without a redeploy.
+func (h *LogHandler) SetLogLevel(c echo.Context) error {
+	var req levelRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.logger.SetLevelName(req.Level); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, levelRequest{Level: h.logger.LevelName()})
+}
+
 // GetRecentLogs returns recent application logs
 func (h *LogHandler) GetRecentLogs(c echo.Context) error {
 	// Parse query parameters
@@ -2,32 +2,42 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/session"
 	"github.com/mckusa/strava-server/internal/strava"
 )
 
 type OAuthHandler struct {
 	queries      *database.Queries
 	stravaClient *strava.Client
+	sessionMgr   *session.Manager
 }
 
-func NewOAuthHandler(queries *database.Queries, stravaClient *strava.Client) *OAuthHandler {
+func NewOAuthHandler(queries *database.Queries, stravaClient *strava.Client, sessionMgr *session.Manager) *OAuthHandler {
 	return &OAuthHandler{
 		queries:      queries,
 		stravaClient: stravaClient,
+		sessionMgr:   sessionMgr,
 	}
 }
 
-// HandleLogin initiates the OAuth flow
+// HandleLogin initiates the OAuth flow, storing a signed CSRF state
+// (and an optional return_to to redirect back to after login) in a
+// short-lived cookie that HandleCallback verifies.
 func (h *OAuthHandler) HandleLogin(c echo.Context) error {
-	// Optional: generate and store state for CSRF protection
-	state := "random_state_string" // In production, generate a secure random state
+	state, err := h.sessionMgr.IssueState(c, c.QueryParam("return_to"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start login: "+err.Error())
+	}
+
 	authURL := h.stravaClient.GetAuthURL(state)
 
 	return c.Redirect(http.StatusTemporaryRedirect, authURL)
@@ -40,12 +50,26 @@ func (h *OAuthHandler) HandleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Missing authorization code")
 	}
 
-	// Optional: verify state parameter
-	// state := c.QueryParam("state")
+	returnTo, ok := h.sessionMgr.VerifyState(c, c.QueryParam("state"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired state parameter")
+	}
 
 	// Exchange authorization code for tokens
 	tokenResp, err := h.stravaClient.ExchangeToken(code)
 	if err != nil {
+		var apiErr *strava.APIError
+		if errors.As(err, &apiErr) {
+			switch {
+			case apiErr.IsUnauthorized():
+				return echo.NewHTTPError(http.StatusUnauthorized, "Strava rejected the authorization code: "+apiErr.Error())
+			case apiErr.IsRateLimited():
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(apiErr.RetryAfter().Seconds())))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Strava rate limit exceeded, try again later")
+			default:
+				return echo.NewHTTPError(http.StatusBadRequest, apiErr.Error())
+			}
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to exchange token: %v", err))
 	}
 
@@ -70,6 +94,14 @@ func (h *OAuthHandler) HandleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to save user: %v", err))
 	}
 
+	if err := h.sessionMgr.IssueSession(c, user.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start session: "+err.Error())
+	}
+
+	if returnTo != "" {
+		return c.Redirect(http.StatusTemporaryRedirect, returnTo)
+	}
+
 	// Return success page
 	html := fmt.Sprintf(`
 		<!DOCTYPE html>
@@ -117,3 +149,9 @@ func (h *OAuthHandler) HandleCallback(c echo.Context) error {
 
 	return c.HTML(http.StatusOK, html)
 }
+
+// HandleLogout clears the caller's session cookie.
+func (h *OAuthHandler) HandleLogout(c echo.Context) error {
+	h.sessionMgr.ClearSession(c)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+// Package metrics holds the server's Prometheus registry and the
+// instruments shared across the HTTP, Strava client, and log sink
+// layers. It's a single package (rather than one registry per layer)
+// so /metrics always reflects everything in one scrape.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry is the process-wide Prometheus registry served at /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTP layer. Keyed by c.Path() (the matched route, e.g.
+	// "/api/activities/recent/:userId") rather than the raw URL so
+	// per-user paths don't blow up cardinality.
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total HTTP requests handled, by route, method, and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency in seconds, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// Strava client layer. Keyed by endpoint (e.g. "GetActivities").
+	StravaRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "strava_requests_total",
+		Help: "Total requests made to the Strava API, by endpoint.",
+	}, []string{"endpoint"})
+
+	StravaErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "strava_errors_total",
+		Help: "Total Strava API requests that failed, by endpoint.",
+	}, []string{"endpoint"})
+
+	StravaRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "strava_retries_total",
+		Help: "Total retries performed against the Strava API, by endpoint.",
+	}, []string{"endpoint"})
+
+	StravaRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "strava_rate_limit_remaining",
+		Help: "Remaining Strava API quota by window (short_term, daily).",
+	}, []string{"window"})
+
+	// logger.DatabaseSink layer.
+	LogSinkBufferedEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "log_sink_buffered_entries",
+		Help: "Log entries currently buffered in the database sink, awaiting flush.",
+	})
+
+	LogSinkFlushesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "log_sink_flushes_total",
+		Help: "Total database sink flush attempts.",
+	})
+
+	LogSinkFlushErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "log_sink_flush_errors_total",
+		Help: "Total database sink flush attempts that failed.",
+	})
+
+	LogSinkFlushDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "log_sink_flush_duration_seconds",
+		Help:    "Database sink flush latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		StravaRequestsTotal,
+		StravaErrorsTotal,
+		StravaRetriesTotal,
+		StravaRateLimitRemaining,
+		LogSinkBufferedEntries,
+		LogSinkFlushesTotal,
+		LogSinkFlushErrorsTotal,
+		LogSinkFlushDuration,
+	)
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// loopbackAddrs are bind addresses NewServer treats as already private
+// to this host, so /metrics needs no further auth on top of them.
+var loopbackAddrs = map[string]bool{
+	"127.0.0.1": true,
+	"localhost": true,
+	"::1":       true,
+}
+
+// NewServer builds an *http.Server exposing GET /metrics on its own
+// bind, separate from the main Echo listener, so scraping doesn't
+// share its auth or port. When bindAddr isn't loopback, /metrics is
+// additionally gated behind esp32APIKey, since process and Go runtime
+// internals shouldn't be exposed to the public internet unauthenticated.
+func NewServer(bindAddr, port, esp32APIKey string) *http.Server {
+	var handler http.Handler = promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if !loopbackAddrs[bindAddr] {
+		handler = requireAPIKey(esp32APIKey, handler)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	return &http.Server{
+		Addr:    net.JoinHostPort(bindAddr, port),
+		Handler: mux,
+	}
+}
+
+// requireAPIKey gates next behind the X-API-Key header, mirroring the
+// ESP32 group's static-key check in package api.
+func requireAPIKey(expectedKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" || key != expectedKey {
+			http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -26,7 +26,7 @@ func (lf *LogFlusher) Start(schedule string) error {
 	}
 
 	_, err := lf.cron.AddFunc(schedule, func() {
-		lf.logger.Info("Flushing logs to database")
+		lf.logger.Info("Flushing logs to configured sinks")
 		if err := lf.logger.Flush(); err != nil {
 			lf.logger.Error("Failed to flush logs", zap.Error(err))
 		}
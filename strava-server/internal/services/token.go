@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/strava"
+)
+
+// TokenRefresher keeps a user's Strava access token usable, refreshing
+// and persisting it when it has expired. It's shared by every path
+// that needs a live access token for a user (the webhook handler, the
+// admin-triggered sync, and the background sync scheduler) so the
+// refresh/persist logic can't drift between them.
+type TokenRefresher struct {
+	queries      *database.Queries
+	stravaClient *strava.Client
+}
+
+// NewTokenRefresher builds a TokenRefresher.
+func NewTokenRefresher(queries *database.Queries, stravaClient *strava.Client) *TokenRefresher {
+	return &TokenRefresher{queries: queries, stravaClient: stravaClient}
+}
+
+// EnsureValidToken returns a usable access token for user, refreshing
+// it via Strava and persisting the new tokens first if it has expired.
+func (r *TokenRefresher) EnsureValidToken(ctx context.Context, user database.User) (string, error) {
+	if !user.TokenExpiresAt.Valid || time.Now().Before(user.TokenExpiresAt.Time) {
+		return user.AccessToken, nil
+	}
+
+	tokenResp, err := r.stravaClient.RefreshToken(user.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
+	_, err = r.queries.UpdateUserTokens(ctx, database.UpdateUserTokensParams{
+		ID:             user.ID,
+		AccessToken:    tokenResp.AccessToken,
+		RefreshToken:   tokenResp.RefreshToken,
+		TokenExpiresAt: pgtype.Timestamp{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tokenResp.AccessToken, nil
+}
@@ -0,0 +1,221 @@
+// Package session issues and verifies HMAC-signed cookies for the
+// OAuth flow: a short-lived state cookie that protects HandleLogin
+// against CSRF, and a longer-lived session cookie that binds a browser
+// to a user ID after a successful callback. Both are stateless (no
+// server-side store), so signing the payload is what keeps them
+// trustworthy.
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	StateCookieName   = "oauth_state"
+	SessionCookieName = "session"
+
+	stateTTL = 10 * time.Minute
+)
+
+// Manager issues and verifies the signed cookies described above.
+type Manager struct {
+	secret     []byte
+	sessionTTL time.Duration
+
+	// secureCookies sets the Secure flag on every cookie this Manager
+	// issues. It must be false for plain-HTTP deployments (e.g. the
+	// default local ESP32-lab setup at http://localhost:8080) since
+	// browsers silently drop Secure cookies over HTTP, which would
+	// break VerifyState on every callback.
+	secureCookies bool
+}
+
+// NewManager builds a Manager. secret signs every cookie it issues;
+// rotating it invalidates all outstanding state and session cookies.
+// secureCookies should be true only when the server is reachable over
+// HTTPS.
+func NewManager(secret []byte, sessionTTL time.Duration, secureCookies bool) *Manager {
+	return &Manager{secret: secret, sessionTTL: sessionTTL, secureCookies: secureCookies}
+}
+
+type statePayload struct {
+	State     string `json:"state"`
+	ReturnTo  string `json:"return_to"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// IssueState generates a random CSRF state value, stores it (along
+// with an optional returnTo URL) in a signed, short-lived cookie, and
+// returns the raw value to embed in the Strava authorization URL.
+func (m *Manager) IssueState(c echo.Context, returnTo string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	state := base64.RawURLEncoding.EncodeToString(raw)
+
+	value, err := m.sign(statePayload{
+		State:     state,
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(stateTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     StateCookieName,
+		Value:    value,
+		Path:     "/auth",
+		HttpOnly: true,
+		Secure:   m.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(stateTTL.Seconds()),
+	})
+
+	return state, nil
+}
+
+// VerifyState checks gotState against the one embedded in the signed
+// state cookie in constant time and returns the return_to URL stored
+// alongside it. The state cookie is cleared either way, so a rejected
+// callback can't be replayed.
+func (m *Manager) VerifyState(c echo.Context, gotState string) (returnTo string, ok bool) {
+	defer m.clearCookie(c, StateCookieName, "/auth")
+
+	cookie, err := c.Cookie(StateCookieName)
+	if err != nil {
+		return "", false
+	}
+
+	var payload statePayload
+	if err := m.verify(cookie.Value, &payload); err != nil {
+		return "", false
+	}
+
+	if time.Now().Unix() > payload.ExpiresAt {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(payload.State), []byte(gotState)) != 1 {
+		return "", false
+	}
+
+	return payload.ReturnTo, true
+}
+
+type sessionPayload struct {
+	UserID    int32 `json:"user_id"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// IssueSession sets a signed session cookie binding the browser to
+// userID for the Manager's configured TTL.
+func (m *Manager) IssueSession(c echo.Context, userID int32) error {
+	value, err := m.sign(sessionPayload{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(m.sessionTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   m.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(m.sessionTTL.Seconds()),
+	})
+	return nil
+}
+
+// UserID returns the user ID bound to the request's session cookie, if
+// it is present, correctly signed, and unexpired.
+func (m *Manager) UserID(c echo.Context) (int32, bool) {
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return 0, false
+	}
+
+	var payload sessionPayload
+	if err := m.verify(cookie.Value, &payload); err != nil {
+		return 0, false
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return 0, false
+	}
+
+	return payload.UserID, true
+}
+
+// ClearSession removes the session cookie, used by logout.
+func (m *Manager) ClearSession(c echo.Context) {
+	m.clearCookie(c, SessionCookieName, "/")
+}
+
+func (m *Manager) clearCookie(c echo.Context, name, path string) {
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		HttpOnly: true,
+		Secure:   m.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+// sign JSON-encodes payload and returns "<payload>.<hmac>", both
+// base64url-encoded.
+func (m *Manager) sign(payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(data)
+	sig := mac.Sum(nil)
+
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// verify splits value into its payload and signature, checks the
+// signature in constant time, and unmarshals the payload into dst.
+func (m *Manager) verify(value string, dst any) error {
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		return errors.New("malformed cookie value")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value[:dot])
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(value[dot+1:])
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(data)
+	expected := mac.Sum(nil)
+	if !hmac.Equal(sig, expected) {
+		return errors.New("invalid cookie signature")
+	}
+
+	return json.Unmarshal(data, dst)
+}
@@ -11,9 +11,10 @@ import (
 )
 
 const (
-	stravaAPIBase = "https://www.strava.com"
-	tokenEndpoint = "/oauth/token"
-	authEndpoint  = "/oauth/authorize"
+	stravaAPIBase        = "https://www.strava.com"
+	tokenEndpoint        = "/oauth/token"
+	authEndpoint         = "/oauth/authorize"
+	subscriptionEndpoint = "/api/v3/push_subscriptions"
 )
 
 type Client struct {
@@ -21,6 +22,7 @@ type Client struct {
 	clientSecret string
 	redirectURI  string
 	httpClient   *http.Client
+	rateLimiter  *rateLimitTransport
 }
 
 type TokenResponse struct {
@@ -39,6 +41,13 @@ type Athlete struct {
 	Lastname  string `json:"lastname"`
 }
 
+// Subscription is a Strava push subscription as returned by the
+// push_subscriptions endpoints.
+type Subscription struct {
+	ID          int64  `json:"id"`
+	CallbackURL string `json:"callback_url"`
+}
+
 type Activity struct {
 	ID             int64     `json:"id"`
 	Name           string    `json:"name"`
@@ -51,12 +60,16 @@ type Activity struct {
 }
 
 func NewClient(clientID, clientSecret, redirectURI string) *Client {
+	rateLimiter := newRateLimitTransport(http.DefaultTransport)
+
 	return &Client{
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		redirectURI:  redirectURI,
+		rateLimiter:  rateLimiter,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: rateLimiter,
 		},
 	}
 }
@@ -119,7 +132,7 @@ func (c *Client) requestToken(data url.Values) (*TokenResponse, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp.StatusCode, body, c.rateLimiter.snapshot())
 	}
 
 	var tokenResp TokenResponse
@@ -162,7 +175,7 @@ func (c *Client) GetActivities(accessToken string, after int64, perPage int) ([]
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp.StatusCode, body, c.rateLimiter.snapshot())
 	}
 
 	var activities []Activity
@@ -173,6 +186,36 @@ func (c *Client) GetActivities(accessToken string, after int64, perPage int) ([]
 	return activities, nil
 }
 
+// GetActivity fetches a single activity by ID, used by the webhook
+// handler to pull just the activity an event refers to.
+func (c *Client) GetActivity(accessToken string, activityID int64) (*Activity, error) {
+	endpoint := fmt.Sprintf("%s/api/v3/activities/%d", stravaAPIBase, activityID)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var activity Activity
+	if err := json.NewDecoder(resp.Body).Decode(&activity); err != nil {
+		return nil, fmt.Errorf("failed to parse activity: %w", err)
+	}
+
+	return &activity, nil
+}
+
 func (c *Client) GetActivitiesInRange(accessToken string, startDayUnix, endDayUnix int64) ([]Activity, error) {
 	allActivities := []Activity{}
 	page := 1
@@ -199,7 +242,7 @@ func (c *Client) GetActivitiesInRange(accessToken string, startDayUnix, endDayUn
 
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+			return nil, newAPIError(resp.StatusCode, body, c.rateLimiter.snapshot())
 		}
 
 		var activities []Activity
@@ -222,3 +265,103 @@ func (c *Client) GetActivitiesInRange(accessToken string, startDayUnix, endDayUn
 
 	return allActivities, nil
 }
+
+// CreateSubscription registers callbackURL with Strava as the target for
+// webhook events. During the validation handshake Strava performs
+// before answering, it sends verifyToken to our GET handler, which
+// must match it and echo back hub.challenge.
+func (c *Client) CreateSubscription(callbackURL, verifyToken string) (*Subscription, error) {
+	data := url.Values{}
+	data.Set("client_id", c.clientID)
+	data.Set("client_secret", c.clientSecret)
+	data.Set("callback_url", callbackURL)
+	data.Set("verify_token", verifyToken)
+
+	endpoint := stravaAPIBase + subscriptionEndpoint
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var sub Subscription
+	if err := json.Unmarshal(body, &sub); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &sub, nil
+}
+
+// ListSubscriptions returns the application's active push subscriptions.
+// Strava only ever allows one at a time, but the endpoint returns a list.
+func (c *Client) ListSubscriptions() ([]Subscription, error) {
+	params := url.Values{}
+	params.Set("client_id", c.clientID)
+	params.Set("client_secret", c.clientSecret)
+
+	reqURL := fmt.Sprintf("%s%s?%s", stravaAPIBase, subscriptionEndpoint, params.Encode())
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var subs []Subscription
+	if err := json.NewDecoder(resp.Body).Decode(&subs); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+// DeleteSubscription removes a push subscription by ID.
+func (c *Client) DeleteSubscription(id int64) error {
+	reqURL := fmt.Sprintf("%s%s/%d", stravaAPIBase, subscriptionEndpoint, id)
+	req, err := http.NewRequest("DELETE", reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("client_id", c.clientID)
+	params.Set("client_secret", c.clientSecret)
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("strava API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
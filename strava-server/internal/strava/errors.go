@@ -0,0 +1,95 @@
+package strava
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// apiErrorDetail is one entry in Strava's {"errors":[{"resource":...,
+// "field":...,"code":...}]} response body.
+type apiErrorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+}
+
+// APIError wraps a non-2xx Strava response so callers can distinguish
+// auth failures, rate limits, and validation errors instead of
+// matching against a formatted error string.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Resource   string
+	Field      string
+	Code       string
+	Body       []byte
+
+	rateLimit RateLimitStatus
+}
+
+// newAPIError parses Strava's error body (falling back to the raw body
+// as the message if it isn't the expected shape) and captures the
+// rate-limit state observed on the same response, which RetryAfter
+// uses to estimate a wait.
+func newAPIError(statusCode int, body []byte, rateLimit RateLimitStatus) *APIError {
+	var parsed struct {
+		Message string           `json:"message"`
+		Errors  []apiErrorDetail `json:"errors"`
+	}
+	_ = json.Unmarshal(body, &parsed)
+
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Message:    parsed.Message,
+		Body:       body,
+		rateLimit:  rateLimit,
+	}
+	if len(parsed.Errors) > 0 {
+		apiErr.Resource = parsed.Errors[0].Resource
+		apiErr.Field = parsed.Errors[0].Field
+		apiErr.Code = parsed.Errors[0].Code
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Resource != "" {
+		return fmt.Sprintf("strava API error (status %d): %s %s %s", e.StatusCode, e.Resource, e.Field, e.Code)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("strava API error (status %d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("strava API error (status %d): %s", e.StatusCode, string(e.Body))
+}
+
+// IsUnauthorized reports whether the access token was rejected as
+// invalid or expired, meaning the caller should force re-login rather
+// than retry.
+func (e *APIError) IsUnauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+// IsRateLimited reports whether Strava rejected the request for quota
+// reasons (429) or asked the caller to back off (503).
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// RetryAfter estimates how long to wait before retrying a rate-limited
+// request, based on whichever quota window (15-minute or daily) was
+// observed as exhausted on the response. It returns 0 if the error
+// wasn't a rate limit or no usage was observed.
+func (e *APIError) RetryAfter() time.Duration {
+	if !e.IsRateLimited() {
+		return 0
+	}
+	if e.rateLimit.ShortTermLimit > 0 && e.rateLimit.ShortTermUsage >= e.rateLimit.ShortTermLimit {
+		return time.Until(e.rateLimit.UpdatedAt.Truncate(shortTermWindow).Add(shortTermWindow))
+	}
+	if e.rateLimit.DailyLimit > 0 && e.rateLimit.DailyUsage >= e.rateLimit.DailyLimit {
+		return time.Until(e.rateLimit.UpdatedAt.Truncate(dailyWindow).Add(dailyWindow))
+	}
+	return 0
+}
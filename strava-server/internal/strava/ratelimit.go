@@ -0,0 +1,208 @@
+package strava
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mckusa/strava-server/internal/metrics"
+)
+
+const (
+	rateLimitRetryAttempts = 5
+	rateLimitBaseBackoff   = time.Second
+	rateLimitMaxBackoff    = 60 * time.Second
+
+	// shortTermWindow and dailyWindow are Strava's two quota windows.
+	// A window's usage resets once UpdatedAt (the last observed
+	// header) rolls past the window boundary following it.
+	shortTermWindow = 15 * time.Minute
+	dailyWindow     = 24 * time.Hour
+)
+
+// RateLimitStatus is the most recently observed Strava rate-limit state,
+// parsed from the X-RateLimit-Limit/X-RateLimit-Usage response headers.
+// Strava reports two windows: a 15-minute window that resets at each
+// quarter hour and a daily one that resets at midnight UTC.
+type RateLimitStatus struct {
+	ShortTermLimit int       `json:"short_term_limit"`
+	ShortTermUsage int       `json:"short_term_usage"`
+	DailyLimit     int       `json:"daily_limit"`
+	DailyUsage     int       `json:"daily_usage"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// exhausted reports whether either window has no budget left, as of
+// the last observed headers. A window whose boundary (UpdatedAt
+// truncated to the window, plus the window) has already passed is
+// treated as replenished regardless of the last recorded usage, since
+// Strava has reset it server-side even though we haven't made a
+// request to observe the fresh count yet.
+func (s RateLimitStatus) exhausted() bool {
+	now := time.Now()
+	if s.ShortTermLimit > 0 && s.ShortTermUsage >= s.ShortTermLimit && now.Before(s.UpdatedAt.Truncate(shortTermWindow).Add(shortTermWindow)) {
+		return true
+	}
+	if s.DailyLimit > 0 && s.DailyUsage >= s.DailyLimit && now.Before(s.UpdatedAt.Truncate(dailyWindow).Add(dailyWindow)) {
+		return true
+	}
+	return false
+}
+
+// rateLimitTransport wraps an http.RoundTripper to track Strava's
+// rate-limit headers and retry 429/503 responses with backoff, so
+// unbounded paging loops (like GetActivitiesInRange) don't silently
+// burn through the quota.
+type rateLimitTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	status RateLimitStatus
+}
+
+func newRateLimitTransport(next http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{next: next}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := rateLimitBaseBackoff
+	endpoint := req.URL.Path
+	metrics.StravaRequestsTotal.WithLabelValues(endpoint).Inc()
+
+	for attempt := 1; attempt <= rateLimitRetryAttempts; attempt++ {
+		if status := t.snapshot(); status.exhausted() {
+			metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+			return nil, fmt.Errorf("strava rate limit exhausted: short-term %d/%d, daily %d/%d",
+				status.ShortTermUsage, status.ShortTermLimit, status.DailyUsage, status.DailyLimit)
+		}
+
+		if attempt > 1 && req.Body != nil {
+			if req.GetBody == nil {
+				// The request body was already consumed on the first
+				// attempt and can't be rewound (e.g. it came from a
+				// plain io.Reader rather than strings/bytes.NewReader),
+				// so retrying would resend an empty body. Give up
+				// rather than send a request Strava would misinterpret.
+				metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+				return nil, fmt.Errorf("strava rate limit: cannot retry %s, request body is not rewindable", endpoint)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+				return nil, fmt.Errorf("strava rate limit: failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+			return nil, err
+		}
+
+		t.record(resp.Header)
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			if resp.StatusCode >= 400 {
+				metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+			}
+			return resp, nil
+		}
+		// A spent quota window won't accept a retry before it resets, so
+		// the response goes back to the caller, whose APIError then
+		// carries the usage RetryAfter estimates the wait from.
+		if attempt == rateLimitRetryAttempts || t.snapshot().exhausted() {
+			metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+			return resp, nil
+		}
+
+		metrics.StravaRetriesTotal.WithLabelValues(endpoint).Inc()
+		wait := retryAfter(resp.Header)
+		if wait == 0 {
+			wait = backoff + jitter(backoff)
+		}
+		resp.Body.Close()
+		time.Sleep(wait)
+
+		backoff *= 2
+		if backoff > rateLimitMaxBackoff {
+			backoff = rateLimitMaxBackoff
+		}
+	}
+
+	metrics.StravaErrorsTotal.WithLabelValues(endpoint).Inc()
+	return nil, fmt.Errorf("strava rate limit: exhausted %d retry attempts", rateLimitRetryAttempts)
+}
+
+func (t *rateLimitTransport) record(header http.Header) {
+	limit, limitOK := parsePair(header.Get("X-RateLimit-Limit"))
+	usage, usageOK := parsePair(header.Get("X-RateLimit-Usage"))
+	if !limitOK && !usageOK {
+		return
+	}
+
+	t.mu.Lock()
+	if limitOK {
+		t.status.ShortTermLimit, t.status.DailyLimit = limit[0], limit[1]
+	}
+	if usageOK {
+		t.status.ShortTermUsage, t.status.DailyUsage = usage[0], usage[1]
+	}
+	t.status.UpdatedAt = time.Now()
+	status := t.status
+	t.mu.Unlock()
+
+	metrics.StravaRateLimitRemaining.WithLabelValues("short_term").Set(float64(status.ShortTermLimit - status.ShortTermUsage))
+	metrics.StravaRateLimitRemaining.WithLabelValues("daily").Set(float64(status.DailyLimit - status.DailyUsage))
+}
+
+func (t *rateLimitTransport) snapshot() RateLimitStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// parsePair parses Strava's "15min,daily" header format, e.g. "100,1000".
+func parsePair(raw string) ([2]int, bool) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 2 {
+		return [2]int{}, false
+	}
+	shortTerm, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	daily, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		return [2]int{}, false
+	}
+	return [2]int{shortTerm, daily}, true
+}
+
+// retryAfter honors a Retry-After header (seconds), returning 0 if absent
+// or unparseable so the caller falls back to exponential backoff.
+func retryAfter(header http.Header) time.Duration {
+	raw := header.Get("Retry-After")
+	if raw == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// jitter returns a random delay in [0, d/2) to avoid synchronized retries.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) / 2))
+}
+
+// RateLimitStatus returns the most recently observed rate-limit state.
+func (c *Client) RateLimitStatus() RateLimitStatus {
+	return c.rateLimiter.snapshot()
+}
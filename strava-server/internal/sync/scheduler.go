@@ -0,0 +1,291 @@
+// Package sync runs a background worker pool that keeps each enabled
+// user's Strava activities up to date on an interval, replacing the
+// purely on-demand polling path in APIHandler.SyncActivities. Work is
+// tracked in the sync_tasks table so the schedule survives restarts.
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+
+	"github.com/mckusa/strava-server/internal/cache"
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/services"
+	"github.com/mckusa/strava-server/internal/strava"
+	"github.com/mckusa/strava-server/pkg/logger"
+)
+
+// DefaultInterval is how often a user's activities are refreshed when
+// Enable is called without an explicit interval.
+const DefaultInterval = 30 * time.Minute
+
+// overlap is subtracted from a task's last_seen_timestamp before each
+// run so activities that started just before the previous run's cutoff
+// aren't missed because of clock skew or Strava's own indexing lag.
+const overlap = 45 * time.Minute
+
+// Scheduler polls for due sync_tasks rows and fans them out to a fixed
+// pool of worker goroutines.
+type Scheduler struct {
+	queries        *database.Queries
+	stravaClient   *strava.Client
+	cache          cache.Cache
+	logger         *logger.Logger
+	workers        int
+	tokenRefresher *services.TokenRefresher
+
+	jobs   chan database.SyncTask
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewScheduler builds a Scheduler with the given worker pool size. It
+// must be started with Start before any task will run.
+func NewScheduler(queries *database.Queries, stravaClient *strava.Client, respCache cache.Cache, log *logger.Logger, workers int, tokenRefresher *services.TokenRefresher) *Scheduler {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &Scheduler{
+		queries:        queries,
+		stravaClient:   stravaClient,
+		cache:          respCache,
+		logger:         log,
+		workers:        workers,
+		tokenRefresher: tokenRefresher,
+		jobs:           make(chan database.SyncTask, workers),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+// Start launches the worker pool and a poller that checks for due
+// tasks every pollInterval. It does not block.
+func (s *Scheduler) Start(pollInterval time.Duration) {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+
+	s.wg.Add(1)
+	go s.poll(pollInterval)
+
+	s.logger.Info("Sync scheduler started", zap.Int("workers", s.workers), zap.Duration("poll_interval", pollInterval))
+}
+
+// Stop signals every worker and the poller to exit and waits for them
+// to drain.
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) poll(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.enqueueDueTasks()
+		}
+	}
+}
+
+func (s *Scheduler) enqueueDueTasks() {
+	ctx := context.Background()
+
+	tasks, err := s.queries.ListDueSyncTasks(ctx, database.ListDueSyncTasksParams{
+		Before: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		Limit:  int32(s.workers * 2),
+	})
+	if err != nil {
+		s.logger.Error("sync: failed to list due tasks", zap.Error(err))
+		return
+	}
+
+	for _, task := range tasks {
+		select {
+		case s.jobs <- task:
+		default:
+			// Worker pool is saturated; the task stays due and will be
+			// picked up on the next poll tick.
+		}
+	}
+}
+
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case task := <-s.jobs:
+			s.runTask(task)
+		}
+	}
+}
+
+// runTask claims task so a concurrent poll tick can't hand it to a
+// second worker, then fetches and upserts every activity since its
+// last_seen_timestamp (minus overlap) and advances the watermark.
+func (s *Scheduler) runTask(task database.SyncTask) {
+	ctx := context.Background()
+
+	claimed, err := s.queries.ClaimSyncTask(ctx, database.ClaimSyncTaskParams{
+		UserID:    task.UserID,
+		NextRunAt: pgtype.Timestamp{Time: time.Now().Add(time.Duration(task.IntervalSeconds) * time.Second), Valid: true},
+	})
+	if err != nil {
+		s.logger.Error("sync: failed to claim task", zap.Int32("user_id", task.UserID), zap.Error(err))
+		return
+	}
+	if claimed == 0 {
+		// Already claimed by another worker, or disabled since it was polled.
+		return
+	}
+
+	user, err := s.queries.GetUserByID(ctx, task.UserID)
+	if err != nil {
+		s.recordError(ctx, task.UserID, err)
+		return
+	}
+
+	accessToken, err := s.tokenRefresher.EnsureValidToken(ctx, user)
+	if err != nil {
+		s.recordError(ctx, task.UserID, err)
+		return
+	}
+
+	since := task.LastSeenTimestamp.Time.Add(-overlap)
+	now := time.Now()
+
+	activities, err := s.stravaClient.GetActivitiesInRange(accessToken, since.Unix(), now.Unix())
+	if err != nil {
+		s.recordError(ctx, task.UserID, err)
+		return
+	}
+
+	for _, activity := range activities {
+		if _, err := s.queries.UpsertActivity(ctx, database.UpsertActivityParams{
+			UserID:           task.UserID,
+			StravaActivityID: activity.ID,
+			Name:             pgtype.Text{String: activity.Name, Valid: true},
+			Type:             pgtype.Text{String: activity.Type, Valid: true},
+			Distance:         pgtype.Float8{Float64: activity.Distance, Valid: true},
+			MovingTime:       pgtype.Int4{Int32: int32(activity.MovingTime), Valid: true},
+			ElapsedTime:      pgtype.Int4{Int32: int32(activity.ElapsedTime), Valid: true},
+			StartDate:        pgtype.Timestamp{Time: activity.StartDate, Valid: true},
+			StartDateLocal:   pgtype.Timestamp{Time: activity.StartDateLocal, Valid: true},
+		}); err != nil {
+			s.logger.Error("sync: failed to upsert activity",
+				zap.Int32("user_id", task.UserID), zap.Int64("activity_id", activity.ID), zap.Error(err))
+		}
+	}
+
+	if _, err := s.queries.UpdateSyncTaskProgress(ctx, database.UpdateSyncTaskProgressParams{
+		UserID:            task.UserID,
+		LastSeenTimestamp: pgtype.Timestamp{Time: now, Valid: true},
+	}); err != nil {
+		s.logger.Error("sync: failed to advance watermark", zap.Int32("user_id", task.UserID), zap.Error(err))
+	}
+
+	if len(activities) > 0 {
+		s.cache.Invalidate(cache.UserPrefix(strconv.Itoa(int(task.UserID))))
+	}
+}
+
+// recordError stores a task's failure and, for errors that carry
+// enough information to act on, adjusts when (or whether) it runs
+// again: an unauthorized response means the user's grant was revoked
+// and retrying won't help until they re-authenticate, while a rate
+// limit with an exhausted quota window reschedules the task for when
+// that window resets, since retrying before then would just get
+// rejected again.
+func (s *Scheduler) recordError(ctx context.Context, userID int32, taskErr error) {
+	s.logger.Error("sync: task failed", zap.Int32("user_id", userID), zap.Error(taskErr))
+
+	nextRunAt := time.Now().Add(DefaultInterval)
+	enabled := true
+
+	var apiErr *strava.APIError
+	if errors.As(taskErr, &apiErr) {
+		switch {
+		case apiErr.IsUnauthorized():
+			enabled = false
+			s.logger.Warn("sync: disabling task after unauthorized Strava response; user must re-authenticate", zap.Int32("user_id", userID))
+		case apiErr.IsRateLimited():
+			if wait := apiErr.RetryAfter(); wait > 0 {
+				nextRunAt = time.Now().Add(wait)
+			}
+		}
+	}
+
+	if _, err := s.queries.UpdateSyncTaskError(ctx, database.UpdateSyncTaskErrorParams{
+		UserID:    userID,
+		LastError: pgtype.Text{String: taskErr.Error(), Valid: true},
+		NextRunAt: pgtype.Timestamp{Time: nextRunAt, Valid: true},
+		Enabled:   enabled,
+	}); err != nil {
+		s.logger.Error("sync: failed to record task error", zap.Int32("user_id", userID), zap.Error(err))
+	}
+}
+
+// Enable turns on background syncing for userID at the given interval,
+// creating its sync_tasks row if one doesn't exist yet, and schedules
+// an immediate first run.
+func (s *Scheduler) Enable(ctx context.Context, userID int32, interval time.Duration) (database.SyncTask, error) {
+	return s.queries.UpsertSyncTask(ctx, database.UpsertSyncTaskParams{
+		UserID:          userID,
+		IntervalSeconds: int32(interval.Seconds()),
+		Enabled:         true,
+		NextRunAt:       pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+}
+
+// Disable turns off background syncing for userID without deleting its
+// watermark, so re-enabling later picks up from where it left off.
+func (s *Scheduler) Disable(ctx context.Context, userID int32) error {
+	_, err := s.queries.SetSyncTaskEnabled(ctx, database.SetSyncTaskEnabledParams{
+		UserID:  userID,
+		Enabled: false,
+	})
+	return err
+}
+
+// Status returns userID's current sync_tasks row.
+func (s *Scheduler) Status(ctx context.Context, userID int32) (database.SyncTask, error) {
+	return s.queries.GetSyncTask(ctx, userID)
+}
+
+// EnqueueImmediate moves userID's next run to now, creating its
+// sync_tasks row with the default interval if one doesn't exist, and
+// returns a job ID the caller can use to correlate with Status later.
+// Only one sync can be in flight per user, so the job ID is derived
+// from the user and the moment it was scheduled rather than a separate
+// job table.
+func (s *Scheduler) EnqueueImmediate(ctx context.Context, userID int32) (string, error) {
+	task, err := s.queries.EnqueueSyncTask(ctx, database.EnqueueSyncTaskParams{
+		UserID:                 userID,
+		DefaultIntervalSeconds: int32(DefaultInterval.Seconds()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return jobID(task.UserID, task.NextRunAt.Time), nil
+}
+
+func jobID(userID int32, at time.Time) string {
+	return fmt.Sprintf("sync-%d-%d", userID, at.UnixNano())
+}
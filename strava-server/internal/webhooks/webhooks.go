@@ -0,0 +1,78 @@
+// Package webhooks implements Strava's Webhook Events API: the GET
+// handshake used to validate a subscription's callback URL, and the
+// event payload Strava POSTs whenever a subscribed athlete creates,
+// updates, or deletes an activity.
+package webhooks
+
+import (
+	"fmt"
+
+	"github.com/mckusa/strava-server/internal/strava"
+)
+
+// ObjectType identifies what kind of Strava object an event refers to.
+type ObjectType string
+
+const (
+	ObjectTypeActivity ObjectType = "activity"
+	ObjectTypeAthlete  ObjectType = "athlete"
+)
+
+// AspectType describes what happened to the object.
+type AspectType string
+
+const (
+	AspectCreate AspectType = "create"
+	AspectUpdate AspectType = "update"
+	AspectDelete AspectType = "delete"
+)
+
+// CallbackPath is the path Strava is configured to push events to.
+const CallbackPath = "/webhooks/strava"
+
+// Event is the JSON body Strava POSTs to the callback URL for every
+// create/update/delete on a subscribed object.
+type Event struct {
+	ObjectType ObjectType        `json:"object_type"`
+	ObjectID   int64             `json:"object_id"`
+	AspectType AspectType        `json:"aspect_type"`
+	OwnerID    int64             `json:"owner_id"`
+	EventTime  int64             `json:"event_time"`
+	Updates    map[string]string `json:"updates"`
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s/%s object=%d owner=%d", e.ObjectType, e.AspectType, e.ObjectID, e.OwnerID)
+}
+
+// VerifyChallenge validates the GET handshake Strava performs when a
+// subscription is created and returns the challenge to echo back.
+func VerifyChallenge(mode, verifyToken, expectedToken, challenge string) (string, bool) {
+	if mode != "subscribe" || challenge == "" || verifyToken != expectedToken {
+		return "", false
+	}
+	return challenge, true
+}
+
+// Bootstrap ensures a push subscription pointed at callbackURL exists,
+// creating one if Strava reports none. It's meant to run once at
+// startup so the webhook is always registered without manual setup.
+func Bootstrap(client *strava.Client, callbackURL, verifyToken string) (*strava.Subscription, error) {
+	subs, err := client.ListSubscriptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	for _, sub := range subs {
+		if sub.CallbackURL == callbackURL {
+			return &sub, nil
+		}
+	}
+
+	sub, err := client.CreateSubscription(callbackURL, verifyToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	return sub, nil
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,17 +14,33 @@ type Config struct {
 	Strava   StravaConfig
 	Database DatabaseConfig
 	Security SecurityConfig
+	Logging  LoggingConfig
+	Cache    CacheConfig
 }
 
 type ServerConfig struct {
 	Port   string
 	Domain string
+
+	// MetricsPort is a separate bind for GET /metrics so scraping
+	// doesn't share the main listener (or its ESP32/admin auth).
+	MetricsPort string
+
+	// MetricsBindAddr is the interface the metrics server binds on,
+	// "127.0.0.1" by default so it's loopback-only. Binding it to a
+	// non-loopback address gates /metrics behind the ESP32 API key
+	// instead, since it's otherwise unauthenticated.
+	MetricsBindAddr string
+
+	// SyncWorkers is the size of the background sync worker pool.
+	SyncWorkers int
 }
 
 type StravaConfig struct {
-	ClientID     string
-	ClientSecret string
-	RedirectURI  string
+	ClientID           string
+	ClientSecret       string
+	RedirectURI        string
+	WebhookVerifyToken string
 }
 
 type DatabaseConfig struct {
@@ -38,6 +56,46 @@ type SecurityConfig struct {
 	ESP32APIKey   string
 	AdminUsername string
 	AdminPassword string
+
+	// ESP32MTLSMode is "off" (API key only), "optional" (mTLS checked
+	// when a client cert is presented, API key otherwise), or
+	// "require" (client cert mandatory, API key path disabled).
+	ESP32MTLSMode   string
+	ESP32MTLSCAFile string
+	ServerCertFile  string
+	ServerKeyFile   string
+
+	// SessionSecret signs the OAuth state and session cookies. Rotating
+	// it logs every browser out and invalidates any login in flight.
+	SessionSecret     string
+	SessionTTLSeconds int
+}
+
+// LoggingConfig selects which logger.Sink implementations are active
+// and configures each one.
+type LoggingConfig struct {
+	// Sinks is the enabled sink list, e.g. []string{"db", "file", "s3"}.
+	Sinks []string
+
+	FilePath       string
+	FileMaxSizeMB  int
+	FileMaxAgeDays int
+	FileGzip       bool
+
+	S3Bucket        string
+	S3Prefix        string
+	S3FlushInterval int // seconds
+}
+
+// CacheConfig selects and configures the internal/cache backend for
+// the ESP32 response cache.
+type CacheConfig struct {
+	Backend   string // "memory" or "redis"
+	RedisAddr string
+
+	RecentTTLSeconds   int
+	CalendarTTLSeconds int
+	StatsTTLSeconds    int
 }
 
 func Load() (*Config, error) {
@@ -46,13 +104,17 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:   getEnv("PORT", "8080"),
-			Domain: getEnv("DOMAIN", "http://localhost:8080"),
+			Port:            getEnv("PORT", "8080"),
+			Domain:          getEnv("DOMAIN", "http://localhost:8080"),
+			MetricsPort:     getEnv("METRICS_PORT", "9090"),
+			MetricsBindAddr: getEnv("METRICS_BIND_ADDR", "127.0.0.1"),
+			SyncWorkers:     getEnvInt("SYNC_WORKERS", 4),
 		},
 		Strava: StravaConfig{
-			ClientID:     getEnv("STRAVA_CLIENT_ID", ""),
-			ClientSecret: getEnv("STRAVA_CLIENT_SECRET", ""),
-			RedirectURI:  getEnv("STRAVA_REDIRECT_URI", ""),
+			ClientID:           getEnv("STRAVA_CLIENT_ID", ""),
+			ClientSecret:       getEnv("STRAVA_CLIENT_SECRET", ""),
+			RedirectURI:        getEnv("STRAVA_REDIRECT_URI", ""),
+			WebhookVerifyToken: getEnv("STRAVA_WEBHOOK_VERIFY_TOKEN", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -63,9 +125,32 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		Security: SecurityConfig{
-			ESP32APIKey:   getEnv("ESP32_API_KEY", ""),
-			AdminUsername: getEnv("ADMIN_USERNAME", "admin"),
-			AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+			ESP32APIKey:       getEnv("ESP32_API_KEY", ""),
+			AdminUsername:     getEnv("ADMIN_USERNAME", "admin"),
+			AdminPassword:     getEnv("ADMIN_PASSWORD", ""),
+			ESP32MTLSMode:     getEnv("ESP32_MTLS_MODE", "off"),
+			ESP32MTLSCAFile:   getEnv("ESP32_MTLS_CA_FILE", ""),
+			ServerCertFile:    getEnv("SERVER_CERT_FILE", ""),
+			ServerKeyFile:     getEnv("SERVER_KEY_FILE", ""),
+			SessionSecret:     getEnv("SESSION_SECRET", ""),
+			SessionTTLSeconds: getEnvInt("SESSION_TTL_SECONDS", 86400),
+		},
+		Logging: LoggingConfig{
+			Sinks:           getEnvList("LOG_SINKS", []string{"db"}),
+			FilePath:        getEnv("LOG_FILE_PATH", "logs/app.log"),
+			FileMaxSizeMB:   getEnvInt("LOG_FILE_MAX_SIZE_MB", 100),
+			FileMaxAgeDays:  getEnvInt("LOG_FILE_MAX_AGE_DAYS", 7),
+			FileGzip:        getEnv("LOG_FILE_GZIP", "true") == "true",
+			S3Bucket:        getEnv("LOG_S3_BUCKET", ""),
+			S3Prefix:        getEnv("LOG_S3_PREFIX", "logs/"),
+			S3FlushInterval: getEnvInt("LOG_S3_FLUSH_INTERVAL_SECONDS", 300),
+		},
+		Cache: CacheConfig{
+			Backend:            getEnv("CACHE_BACKEND", "memory"),
+			RedisAddr:          getEnv("CACHE_REDIS_ADDR", ""),
+			RecentTTLSeconds:   getEnvInt("CACHE_RECENT_TTL_SECONDS", 60),
+			CalendarTTLSeconds: getEnvInt("CACHE_CALENDAR_TTL_SECONDS", 600),
+			StatsTTLSeconds:    getEnvInt("CACHE_STATS_TTL_SECONDS", 300),
 		},
 	}
 
@@ -89,6 +174,18 @@ func (c *Config) Validate() error {
 	if c.Security.ESP32APIKey == "" {
 		return fmt.Errorf("ESP32_API_KEY is required")
 	}
+	if c.Security.SessionSecret == "" {
+		return fmt.Errorf("SESSION_SECRET is required")
+	}
+	switch c.Security.ESP32MTLSMode {
+	case "off":
+	case "optional", "require":
+		if c.Security.ESP32MTLSCAFile == "" || c.Security.ServerCertFile == "" || c.Security.ServerKeyFile == "" {
+			return fmt.Errorf("ESP32_MTLS_CA_FILE, SERVER_CERT_FILE, and SERVER_KEY_FILE are required when ESP32_MTLS_MODE=%s", c.Security.ESP32MTLSMode)
+		}
+	default:
+		return fmt.Errorf("invalid ESP32_MTLS_MODE %q", c.Security.ESP32MTLSMode)
+	}
 	return nil
 }
 
@@ -113,3 +210,32 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList parses a comma-separated env var like "db,file,s3".
+func getEnvList(key string, defaultValue []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+
+	parts := strings.Split(raw, ",")
+	values := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/internal/metrics"
+)
+
+// DatabaseSink buffers log entries and writes them to Postgres in
+// batches, either when the buffer fills or when Flush is called.
+type DatabaseSink struct {
+	queries *database.Queries
+	buffer  []LogEntry
+	mu      sync.Mutex
+	maxSize int
+}
+
+// NewDatabaseSink creates a new database sink.
+func NewDatabaseSink(queries *database.Queries, maxSize int) *DatabaseSink {
+	return &DatabaseSink{
+		queries: queries,
+		buffer:  make([]LogEntry, 0, maxSize),
+		maxSize: maxSize,
+	}
+}
+
+// Write buffers entry, flushing immediately if the buffer is full.
+func (ds *DatabaseSink) Write(entry LogEntry) error {
+	ds.mu.Lock()
+	ds.buffer = append(ds.buffer, entry)
+	needsFlush := len(ds.buffer) >= ds.maxSize
+	bufferedLen := len(ds.buffer)
+	ds.mu.Unlock()
+
+	metrics.LogSinkBufferedEntries.Set(float64(bufferedLen))
+
+	if needsFlush {
+		return ds.Flush(context.Background())
+	}
+	return nil
+}
+
+// Flush writes all buffered entries to the database.
+func (ds *DatabaseSink) Flush(ctx context.Context) error {
+	ds.mu.Lock()
+	if len(ds.buffer) == 0 {
+		ds.mu.Unlock()
+		return nil
+	}
+
+	entries := make([]LogEntry, len(ds.buffer))
+	copy(entries, ds.buffer)
+	ds.buffer = ds.buffer[:0]
+	ds.mu.Unlock()
+
+	metrics.LogSinkBufferedEntries.Set(0)
+	metrics.LogSinkFlushesTotal.Inc()
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows := make([]database.InsertLogBatchParams, len(entries))
+	for i, entry := range entries {
+		fieldsJSON, _ := json.Marshal(entry.Fields)
+		rows[i] = database.InsertLogBatchParams{
+			Level:      entry.Level,
+			Message:    entry.Message,
+			Timestamp:  pgtype.Timestamp{Time: entry.Timestamp, Valid: true},
+			Caller:     pgtype.Text{String: entry.Caller, Valid: entry.Caller != ""},
+			StackTrace: pgtype.Text{String: entry.StackTrace, Valid: entry.StackTrace != ""},
+			Fields:     fieldsJSON,
+		}
+	}
+
+	_, err := ds.queries.InsertLogBatch(ctx, rows)
+
+	metrics.LogSinkFlushDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.LogSinkFlushErrorsTotal.Inc()
+	}
+
+	return err
+}
+
+// Close flushes any remaining buffered entries.
+func (ds *DatabaseSink) Close() error {
+	return ds.Flush(context.Background())
+}
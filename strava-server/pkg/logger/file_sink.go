@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSink appends NDJSON log entries to a file on disk, rotating it
+// once it exceeds maxSize (in bytes) or maxAge, and optionally gzipping the
+// rotated segment.
+type FileSink struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	gzip    bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileSink opens (or creates) path for appending. maxSizeMB and
+// maxAgeDays of 0 disable that rotation trigger.
+func NewFileSink(path string, maxSizeMB, maxAgeDays int, gzipRotated bool) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	fs := &FileSink{
+		path:    path,
+		maxSize: int64(maxSizeMB) * 1024 * 1024,
+		maxAge:  time.Duration(maxAgeDays) * 24 * time.Hour,
+		gzip:    gzipRotated,
+	}
+
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *FileSink) open() error {
+	file, err := os.OpenFile(fs.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	fs.file = file
+	fs.size = info.Size()
+	fs.openedAt = time.Now()
+	return nil
+}
+
+// Write appends entry as a JSON line, rotating first if needed.
+func (fs *FileSink) Write(entry LogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.needsRotation(int64(len(line))) {
+		if err := fs.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := fs.file.Write(line)
+	fs.size += int64(n)
+	return err
+}
+
+func (fs *FileSink) needsRotation(nextWrite int64) bool {
+	if fs.maxSize > 0 && fs.size+nextWrite > fs.maxSize {
+		return true
+	}
+	if fs.maxAge > 0 && time.Since(fs.openedAt) > fs.maxAge {
+		return true
+	}
+	return false
+}
+
+func (fs *FileSink) rotateLocked() error {
+	if err := fs.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := fmt.Sprintf("%s.%s", fs.path, time.Now().Format("20060102T150405"))
+	if err := os.Rename(fs.path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if fs.gzip {
+		if err := gzipFile(rotated); err != nil {
+			return fmt.Errorf("failed to gzip rotated log: %w", err)
+		}
+	}
+
+	return fs.open()
+}
+
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Flush fsyncs the current file to disk.
+func (fs *FileSink) Flush(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.file.Sync()
+}
+
+// Close flushes and closes the current file.
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	_ = fs.file.Sync()
+	return fs.file.Close()
+}
@@ -2,146 +2,37 @@ package logger
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"os"
-	"sync"
-	"time"
 
-	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/mckusa/strava-server/internal/database"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-)
-
-// LogEntry represents a buffered log entry
-type LogEntry struct {
-	Level      string
-	Message    string
-	Timestamp  time.Time
-	Caller     string
-	StackTrace string
-	Fields     map[string]any
-}
-
-// DatabaseSink is a custom Zap sink that buffers logs for batch DB writes
-type DatabaseSink struct {
-	queries *database.Queries
-	buffer  []LogEntry
-	mu      sync.Mutex
-	maxSize int
-}
-
-// NewDatabaseSink creates a new database sink
-func NewDatabaseSink(queries *database.Queries, maxSize int) *DatabaseSink {
-	return &DatabaseSink{
-		queries: queries,
-		buffer:  make([]LogEntry, 0, maxSize),
-		maxSize: maxSize,
-	}
-}
-
-// Write implements zapcore.WriteSyncer
-func (ds *DatabaseSink) Write(p []byte) (n int, err error) {
-	var entry map[string]any
-	if err := json.Unmarshal(p, &entry); err != nil {
-		return 0, err
-	}
-
-	logEntry := LogEntry{
-		Level:     getString(entry, "level"),
-		Message:   getString(entry, "msg"),
-		Timestamp: time.Now(),
-		Caller:    getString(entry, "caller"),
-		Fields:    entry,
-	}
-
-	if stacktrace, ok := entry["stacktrace"].(string); ok {
-		logEntry.StackTrace = stacktrace
-	}
-
-	ds.mu.Lock()
-	ds.buffer = append(ds.buffer, logEntry)
-	needsFlush := len(ds.buffer) >= ds.maxSize
-	ds.mu.Unlock()
-
-	if needsFlush {
-		ds.Flush()
-	}
-
-	return len(p), nil
-}
-
-// Sync implements zapcore.WriteSyncer
-func (ds *DatabaseSink) Sync() error {
-	return ds.Flush()
-}
 
-// Flush writes all buffered logs to the database
-func (ds *DatabaseSink) Flush() error {
-	ds.mu.Lock()
-	if len(ds.buffer) == 0 {
-		ds.mu.Unlock()
-		return nil
-	}
-
-	entries := make([]LogEntry, len(ds.buffer))
-	copy(entries, ds.buffer)
-	ds.buffer = ds.buffer[:0]
-	ds.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	rows := make([]database.InsertLogBatchParams, len(entries))
-
-	for i, entry := range entries {
-		fieldsJSON, _ := json.Marshal(entry.Fields)
-		rows[i] = database.InsertLogBatchParams{
-			Level:      entry.Level,
-			Message:    entry.Message,
-			Timestamp:  pgtype.Timestamp{Time: entry.Timestamp, Valid: true},
-			Caller:     pgtype.Text{String: entry.Caller, Valid: entry.Caller != ""},
-			StackTrace: pgtype.Text{String: entry.StackTrace, Valid: entry.StackTrace != ""},
-			Fields:     fieldsJSON,
-		}
-	}
-
-	_, err := ds.queries.InsertLogBatch(ctx, rows)
-	return err
-}
-
-func getString(m map[string]any, key string) string {
-	if v, ok := m[key]; ok {
-		if s, ok := v.(string); ok {
-			return s
-		}
-	}
-	return ""
-}
+	"github.com/mckusa/strava-server/internal/database"
+	"github.com/mckusa/strava-server/pkg/config"
+)
 
-// Logger wraps zap.Logger with database sink
+// Logger wraps zap.Logger and owns the sinks feeding it so Flush can
+// fan out to all of them, not just the database. Level is an atomic
+// zap level shared by every core, so verbosity can be changed at
+// runtime without rebuilding the logger.
 type Logger struct {
 	*zap.Logger
-	dbSink *DatabaseSink
+	Level zap.AtomicLevel
+	sinks []Sink
 }
 
-// NewLogger creates a new logger with console and database outputs
-func NewLogger(queries *database.Queries, isDevelopment bool) (*Logger, error) {
-	consoleEncoderConfig := zapcore.EncoderConfig{
-		TimeKey:        "time",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.CapitalColorLevelEncoder,
-		EncodeTime:     zapcore.ISO8601TimeEncoder,
-		EncodeDuration: zapcore.SecondsDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
+// NewLogger builds a console core plus one core per sink named in
+// logging.Sinks (e.g. "db", "file", "s3"), tees them together, and
+// samples each sink core's Debug/Info entries so a burst can't
+// overwhelm it; Warn and above are never sampled.
+func NewLogger(queries *database.Queries, logging config.LoggingConfig, isDevelopment bool) (*Logger, error) {
+	sinkNames := logging.Sinks
+	if len(sinkNames) == 0 {
+		sinkNames = []string{"db"}
 	}
 
-	dbEncoderConfig := zapcore.EncoderConfig{
+	consoleEncoderConfig := zapcore.EncoderConfig{
 		TimeKey:        "time",
 		LevelKey:       "level",
 		NameKey:        "logger",
@@ -149,41 +40,100 @@ func NewLogger(queries *database.Queries, isDevelopment bool) (*Logger, error) {
 		MessageKey:     "msg",
 		StacktraceKey:  "stacktrace",
 		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeLevel:    zapcore.CapitalColorLevelEncoder,
 		EncodeTime:     zapcore.ISO8601TimeEncoder,
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	dbSink := NewDatabaseSink(queries, 100)
+	sinkEncoderConfig := consoleEncoderConfig
+	sinkEncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
 
-	level := zapcore.InfoLevel
+	initialLevel := zapcore.InfoLevel
 	if isDevelopment {
-		level = zapcore.DebugLevel
+		initialLevel = zapcore.DebugLevel
 	}
+	atomicLevel := zap.NewAtomicLevelAt(initialLevel)
 
-	consoleCore := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(consoleEncoderConfig),
-		zapcore.AddSync(os.Stdout),
-		level,
-	)
+	cores := []zapcore.Core{
+		zapcore.NewCore(zapcore.NewConsoleEncoder(consoleEncoderConfig), zapcore.AddSync(os.Stdout), atomicLevel),
+	}
 
-	dbCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(dbEncoderConfig),
-		zapcore.AddSync(dbSink),
-		level,
-	)
+	sinks := make([]Sink, 0, len(sinkNames))
+	for _, name := range sinkNames {
+		sink, err := newSink(name, queries, logging)
+		if err != nil {
+			return nil, err
+		}
 
-	core := zapcore.NewTee(consoleCore, dbCore)
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(sinkEncoderConfig), zapcore.AddSync(newSinkWriteSyncer(sink)), atomicLevel)
+		cores = append(cores, sampled(core))
+		sinks = append(sinks, sink)
+	}
+
+	core := zapcore.NewTee(cores...)
 	zapLogger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 
 	return &Logger{
 		Logger: zapLogger,
-		dbSink: dbSink,
+		Level:  atomicLevel,
+		sinks:  sinks,
 	}, nil
 }
 
-// Flush flushes the database sink
+func newSink(name string, queries *database.Queries, logging config.LoggingConfig) (Sink, error) {
+	switch name {
+	case "db":
+		return NewDatabaseSink(queries, 100), nil
+	case "file":
+		return NewFileSink(logging.FilePath, logging.FileMaxSizeMB, logging.FileMaxAgeDays, logging.FileGzip)
+	case "s3":
+		uploader, err := NewAWSUploader(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return NewS3Sink(uploader, logging.S3Bucket, logging.S3Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown log sink %q", name)
+	}
+}
+
+// LevelName returns the current level as the lowercase string used by
+// SetLevelName and the admin API ("debug", "info", "warn", "error").
+func (l *Logger) LevelName() string {
+	return l.Level.Level().String()
+}
+
+// SetLevelName updates the shared atomic level from a string like
+// "debug", "info", "warn", or "error", taking effect on every core
+// immediately without rebuilding the logger.
+func (l *Logger) SetLevelName(name string) error {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	l.Level.SetLevel(level)
+	return nil
+}
+
+// Flush flushes every configured sink.
 func (l *Logger) Flush() error {
-	return l.dbSink.Flush()
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Flush(context.Background()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close flushes and releases every configured sink.
+func (l *Logger) Close() error {
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
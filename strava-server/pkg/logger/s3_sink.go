@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// S3Uploader is the subset of an S3 client S3Sink needs. Kept minimal
+// so tests can fake it without pulling in the AWS SDK.
+type S3Uploader interface {
+	Upload(ctx context.Context, bucket, key string, body []byte) error
+}
+
+// S3Sink batches log entries into gzipped NDJSON objects and ships
+// them to object storage. It relies on the caller (LogFlusher) to
+// invoke Flush on a schedule rather than running its own ticker.
+type S3Sink struct {
+	uploader S3Uploader
+	bucket   string
+	prefix   string
+
+	mu     sync.Mutex
+	buffer []LogEntry
+}
+
+// NewS3Sink creates a sink that batches entries and flushes them to
+// bucket/prefix each time Flush is called.
+func NewS3Sink(uploader S3Uploader, bucket, prefix string) *S3Sink {
+	return &S3Sink{
+		uploader: uploader,
+		bucket:   bucket,
+		prefix:   prefix,
+	}
+}
+
+// Write buffers entry for the next scheduled Flush.
+func (s *S3Sink) Write(entry LogEntry) error {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, entry)
+	s.mu.Unlock()
+	return nil
+}
+
+// Flush gzips the buffered NDJSON batch and uploads it as one object.
+func (s *S3Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	if len(s.buffer) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	entries := make([]LogEntry, len(s.buffer))
+	copy(entries, s.buffer)
+	s.buffer = s.buffer[:0]
+	s.mu.Unlock()
+
+	var body bytes.Buffer
+	gw := gzip.NewWriter(&body)
+	enc := json.NewEncoder(gw)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			gw.Close()
+			return fmt.Errorf("failed to encode log batch: %w", err)
+		}
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("failed to gzip log batch: %w", err)
+	}
+
+	key := fmt.Sprintf("%s%s.ndjson.gz", s.prefix, time.Now().Format("20060102T150405.000000000"))
+	return s.uploader.Upload(ctx, s.bucket, key, body.Bytes())
+}
+
+// Close performs a final flush; there is no persistent connection to
+// release otherwise.
+func (s *S3Sink) Close() error {
+	return s.Flush(context.Background())
+}
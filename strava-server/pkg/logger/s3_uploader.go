@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// awsUploader implements S3Uploader against a real S3 (or
+// S3-compatible) bucket using the AWS SDK's default credential chain.
+type awsUploader struct {
+	client *s3.Client
+}
+
+// NewAWSUploader builds an S3Uploader from the process's default AWS
+// config (env vars, shared config/credentials files, or an assumed
+// role, per the SDK's usual resolution order).
+func NewAWSUploader(ctx context.Context) (S3Uploader, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &awsUploader{client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (u *awsUploader) Upload(ctx context.Context, bucket, key string, body []byte) error {
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(body),
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	return err
+}
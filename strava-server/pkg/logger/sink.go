@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LogEntry represents a single structured log line, decoded from the
+// JSON the Zap encoder produces.
+type LogEntry struct {
+	Level      string
+	Message    string
+	Timestamp  time.Time
+	Caller     string
+	StackTrace string
+	Fields     map[string]any
+}
+
+// Sink receives log entries and is responsible for getting them to
+// wherever they're durably stored. Implementations buffer internally
+// as they see fit; Flush is the caller's signal to push any buffered
+// entries out now.
+type Sink interface {
+	Write(entry LogEntry) error
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// sinkWriteSyncer adapts a Sink to zapcore.WriteSyncer so it can be
+// plugged into a zapcore.Core, decoding the JSON-encoded line Zap
+// writes back into a LogEntry.
+type sinkWriteSyncer struct {
+	sink Sink
+}
+
+func newSinkWriteSyncer(sink Sink) *sinkWriteSyncer {
+	return &sinkWriteSyncer{sink: sink}
+}
+
+func (s *sinkWriteSyncer) Write(p []byte) (int, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(p, &raw); err != nil {
+		return 0, err
+	}
+
+	entry := LogEntry{
+		Level:     getString(raw, "level"),
+		Message:   getString(raw, "msg"),
+		Timestamp: time.Now(),
+		Caller:    getString(raw, "caller"),
+		Fields:    raw,
+	}
+	if stacktrace, ok := raw["stacktrace"].(string); ok {
+		entry.StackTrace = stacktrace
+	}
+
+	if err := s.sink.Write(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *sinkWriteSyncer) Sync() error {
+	return s.sink.Flush(context.Background())
+}
+
+func getString(m map[string]any, key string) string {
+	if v, ok := m[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// samplerOptions are the defaults used when wrapping a sink's core in
+// zapcore.NewSamplerWithOptions, so noisy Info logs don't overwhelm a
+// sink like the database one under load.
+var samplerOptions = struct {
+	tick       time.Duration
+	first      int
+	thereafter int
+}{
+	tick:       time.Second,
+	first:      20,
+	thereafter: 100,
+}
+
+// sampled wraps core so Debug/Info entries are sampled but Warn,
+// Error, and above always pass through unsampled. Crash logs (the
+// panic-recover middleware's entries, in particular) can't afford to
+// be dropped under the same burst the sampler exists to protect
+// against.
+func sampled(core zapcore.Core) zapcore.Core {
+	return &levelGatedSampler{
+		core:    core,
+		sampled: zapcore.NewSamplerWithOptions(core, samplerOptions.tick, samplerOptions.first, samplerOptions.thereafter),
+	}
+}
+
+// levelGatedSampler routes each entry to the sampled core below Warn,
+// and straight to the unsampled core at Warn and above.
+type levelGatedSampler struct {
+	core    zapcore.Core
+	sampled zapcore.Core
+}
+
+func (c *levelGatedSampler) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+func (c *levelGatedSampler) With(fields []zapcore.Field) zapcore.Core {
+	return &levelGatedSampler{core: c.core.With(fields), sampled: c.sampled.With(fields)}
+}
+
+func (c *levelGatedSampler) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level >= zapcore.WarnLevel {
+		return c.core.Check(entry, ce)
+	}
+	return c.sampled.Check(entry, ce)
+}
+
+func (c *levelGatedSampler) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.core.Write(entry, fields)
+}
+
+func (c *levelGatedSampler) Sync() error {
+	return c.core.Sync()
+}